@@ -0,0 +1,518 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+/*
+	Control API
+
+	A small HTTP/JSON API for driving the emulator from scripts, bound to
+	localhost.  Every handler takes the machine lock, so it is safe to call
+	while processTicks is running.  Addresses and values are plain JSON
+	numbers, query string addresses are hex.
+
+	Requests must name a localhost Host, and a localhost Origin if they have
+	one, so a web page can't drive the emulator through the browser.  For
+	the same reason JSON bodies must be sent as application/json and
+	/api/load's as application/octet-stream, which a page can't POST
+	without the browser asking first.
+
+	GET    /api/status                  run state, PC and cycle count
+	POST   /api/pause                   stop the CPU (single step mode)
+	POST   /api/resume                  run the CPU
+	POST   /api/step?count=n            run n instructions while paused
+	POST   /api/reset                   reset the CPU
+	GET    /api/registers               read registers
+	POST   /api/registers               write registers, {"a": 1, "pc": 512}
+	GET    /api/memory?addr=0200&len=16 read memory, add &bank=n for a bank
+	POST   /api/memory                  write memory, {"addr": 512, "data": [1, 2]}
+	POST   /api/load?addr=0280          load the raw request body at addr,
+	                                    ROM included
+	POST   /api/type                    type text, {"text": "E000R\n"}
+	GET    /api/display                 the screen as text
+	GET    /api/breakpoints             list breakpoints
+	POST   /api/breakpoints?addr=ff00   add a breakpoint
+	DELETE /api/breakpoints?addr=ff00   remove a breakpoint
+	GET    /api/watchpoints             list watchpoints
+	POST   /api/watchpoints?addr=24&end=25&access=rw
+	                                    stop on memory access, access is
+	                                    any of r, w and x (default w)
+	DELETE /api/watchpoints?id=1        remove a watchpoint
+*/
+
+type apiStatus struct {
+	Running bool   `json:"running"`
+	Halted  bool   `json:"halted"`
+	PC      uint16 `json:"pc"`
+	Cycles  uint64 `json:"cycles"`
+}
+
+type apiRegisters struct {
+	PC     *uint16 `json:"pc,omitempty"`
+	SP     *uint8  `json:"sp,omitempty"`
+	A      *uint8  `json:"a,omitempty"`
+	X      *uint8  `json:"x,omitempty"`
+	Y      *uint8  `json:"y,omitempty"`
+	Status *uint8  `json:"status,omitempty"`
+}
+
+type apiMemory struct {
+	Addr uint16 `json:"addr"`
+	Data []int  `json:"data"`
+}
+
+type apiText struct {
+	Text string `json:"text"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func startAPI(addr string) error {
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", apiHandler(http.MethodGet, apiGetStatus))
+	mux.HandleFunc("/api/pause", apiHandler(http.MethodPost, apiPause))
+	mux.HandleFunc("/api/resume", apiHandler(http.MethodPost, apiResume))
+	mux.HandleFunc("/api/step", apiHandler(http.MethodPost, apiStep))
+	mux.HandleFunc("/api/reset", apiHandler(http.MethodPost, apiReset))
+	mux.HandleFunc("/api/registers", apiRegistersHandler)
+	mux.HandleFunc("/api/memory", apiMemoryHandler)
+	mux.HandleFunc("/api/load", apiHandler(http.MethodPost, apiLoad))
+	mux.HandleFunc("/api/type", apiHandler(http.MethodPost, apiType))
+	mux.HandleFunc("/api/display", apiHandler(http.MethodGet, apiDisplay))
+	mux.HandleFunc("/api/breakpoints", apiBreakpointsHandler)
+	mux.HandleFunc("/api/watchpoints", apiWatchpointsHandler)
+
+	fmt.Fprintf(os.Stderr, "Control API on http://%v/api/\n", l.Addr())
+	go http.Serve(l, apiLocal(mux))
+	return nil
+}
+
+// apiLocal refuses requests that don't come from this machine
+func apiLocal(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := localRequest(r); err != nil {
+			apiWrite(w, http.StatusForbidden, apiError{err.Error()})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// localRequest checks that r was sent to localhost, which stops a DNS
+// rebinding, and from a localhost page when a browser sent it
+func localRequest(r *http.Request) error {
+	if !localHost(r.Host) {
+		return fmt.Errorf("host %q is not localhost", r.Host)
+	}
+	if origin := r.Header.Get("Origin"); len(origin) > 0 {
+		u, err := url.Parse(origin)
+		if err != nil || !localHost(u.Host) {
+			return fmt.Errorf("origin %q is not localhost", origin)
+		}
+	}
+	return nil
+}
+
+// localHost reports whether host, with or without a port, is a loopback
+// name or address
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type apiFunc func(r *http.Request) (any, error)
+
+// apiHandler restricts f to a single method and writes its result as JSON
+func apiHandler(method string, f apiFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			apiWrite(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%v not allowed", r.Method)})
+			return
+		}
+		apiRun(w, r, f)
+	}
+}
+
+func apiRun(w http.ResponseWriter, r *http.Request, f apiFunc) {
+	v, err := f(r)
+	if err != nil {
+		apiWrite(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	apiWrite(w, http.StatusOK, v)
+}
+
+func apiWrite(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiContentType checks that the request body is a contentType
+func apiContentType(r *http.Request, contentType string) error {
+	t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || t != contentType {
+		return fmt.Errorf("Content-Type must be %v", contentType)
+	}
+	return nil
+}
+
+// apiDecode reads a JSON request body into v
+func apiDecode(r *http.Request, v any) error {
+	if err := apiContentType(r, "application/json"); err != nil {
+		return err
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// apiAddr parses a hex address from the query string
+func apiAddr(r *http.Request, name string) (uint16, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(r.URL.Query().Get(name), "$"), "0x")
+	if len(s) == 0 {
+		return 0, fmt.Errorf("missing %v", name)
+	}
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %q", name, s)
+	}
+	return uint16(v), nil
+}
+
+func apiGetStatus(r *http.Request) (any, error) {
+	machine.Lock()
+	defer machine.Unlock()
+	return apiStatus{
+		Running: !cpu.SingleStep,
+		Halted:  cpu.IsHalted(),
+		PC:      cpu.PC,
+		Cycles:  cpu.Cycles,
+	}, nil
+}
+
+func apiPause(r *http.Request) (any, error) {
+	machine.Lock()
+	cpu.SingleStep = true
+	machine.Unlock()
+	return apiGetStatus(r)
+}
+
+func apiResume(r *http.Request) (any, error) {
+	machine.Lock()
+	cpu.SingleStep = false
+	machine.Unlock()
+	return apiGetStatus(r)
+}
+
+func apiStep(r *http.Request) (any, error) {
+	count := 1
+	if c := r.URL.Query().Get("count"); len(c) > 0 {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count %q", c)
+		}
+		count = n
+	}
+	if !paused() {
+		return nil, fmt.Errorf("CPU is running, pause it first")
+	}
+	for i := 0; i < count; i++ {
+		if step() {
+			break
+		}
+	}
+	return apiGetStatus(r)
+}
+
+func apiReset(r *http.Request) (any, error) {
+	machine.Lock()
+	err := resetCPU()
+	machine.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return apiGetStatus(r)
+}
+
+func apiRegistersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiRun(w, r, apiGetRegisters)
+	case http.MethodPost, http.MethodPut:
+		apiRun(w, r, apiSetRegisters)
+	default:
+		apiWrite(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%v not allowed", r.Method)})
+	}
+}
+
+func apiGetRegisters(r *http.Request) (any, error) {
+	machine.Lock()
+	pc, sp, a, x, y, status := cpu.PC, cpu.SP, cpu.A, cpu.X, cpu.Y, cpu.Status
+	machine.Unlock()
+	return apiRegisters{
+		PC:     &pc,
+		SP:     &sp,
+		A:      &a,
+		X:      &x,
+		Y:      &y,
+		Status: &status,
+	}, nil
+}
+
+func apiSetRegisters(r *http.Request) (any, error) {
+	var regs apiRegisters
+	if err := apiDecode(r, &regs); err != nil {
+		return nil, err
+	}
+	machine.Lock()
+	if regs.PC != nil {
+		cpu.PC = *regs.PC
+	}
+	if regs.SP != nil {
+		cpu.SP = *regs.SP
+	}
+	if regs.A != nil {
+		cpu.A = *regs.A
+	}
+	if regs.X != nil {
+		cpu.X = *regs.X
+	}
+	if regs.Y != nil {
+		cpu.Y = *regs.Y
+	}
+	if regs.Status != nil {
+		cpu.Status = *regs.Status
+	}
+	machine.Unlock()
+	return apiGetRegisters(r)
+}
+
+func apiMemoryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiRun(w, r, apiGetMemory)
+	case http.MethodPost, http.MethodPut:
+		apiRun(w, r, apiSetMemory)
+	default:
+		apiWrite(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%v not allowed", r.Method)})
+	}
+}
+
+func apiGetMemory(r *http.Request) (any, error) {
+	addr, err := apiAddr(r, "addr")
+	if err != nil {
+		return nil, err
+	}
+	size := 1
+	if l := r.URL.Query().Get("len"); len(l) > 0 {
+		n, err := strconv.ParseUint(l, 10, 32)
+		if err != nil || n < 1 || n > 0x10000 {
+			return nil, fmt.Errorf("invalid len %q", l)
+		}
+		size = int(n)
+	}
+	bank := -1
+	if b := r.URL.Query().Get("bank"); len(b) > 0 {
+		n, err := strconv.Atoi(b)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid bank %q", b)
+		}
+		bank = n
+	}
+
+	machine.Lock()
+	defer machine.Unlock()
+	m := apiMemory{
+		Addr: addr,
+		Data: make([]int, size),
+	}
+	for i := range m.Data {
+		var b byte
+		if bank >= 0 {
+			b, _ = io.PeekBank(bank, addr+uint16(i))
+		} else {
+			b, _ = io.Peek(addr + uint16(i))
+		}
+		m.Data[i] = int(b)
+	}
+	return m, nil
+}
+
+func apiSetMemory(r *http.Request) (any, error) {
+	var m apiMemory
+	if err := apiDecode(r, &m); err != nil {
+		return nil, err
+	}
+
+	machine.Lock()
+	defer machine.Unlock()
+	for i, b := range m.Data {
+		if b < 0 || b > 0xFF {
+			return nil, fmt.Errorf("data[%v] = %v is not a byte", i, b)
+		}
+		addr := m.Addr + uint16(i)
+		if err := io.Set(addr, byte(b)); err != nil {
+			return nil, fmt.Errorf("write to $%04x failed: %v", addr, err)
+		}
+	}
+	return m, nil
+}
+
+func apiLoad(r *http.Request) (any, error) {
+	addr, err := apiAddr(r, "addr")
+	if err != nil {
+		return nil, err
+	}
+	if err := apiContentType(r, "application/octet-stream"); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	machine.Lock()
+	defer machine.Unlock()
+	if _, err := io.LoadAt(addr, data); err != nil {
+		return nil, err
+	}
+	return apiMemory{Addr: addr, Data: []int{}}, nil
+}
+
+func apiType(r *http.Request) (any, error) {
+	var t apiText
+	if err := apiDecode(r, &t); err != nil {
+		return nil, err
+	}
+	for _, b := range []byte(t.Text) {
+		typeKey(b)
+	}
+	return t, nil
+}
+
+func apiDisplay(r *http.Request) (any, error) {
+	return apiText{display.All(false)}, nil
+}
+
+func apiBreakpointsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiRun(w, r, apiGetBreakpoints)
+	case http.MethodPost, http.MethodPut:
+		apiRun(w, r, func(r *http.Request) (any, error) {
+			return apiSetBreakpoint(r, true)
+		})
+	case http.MethodDelete:
+		apiRun(w, r, func(r *http.Request) (any, error) {
+			return apiSetBreakpoint(r, false)
+		})
+	default:
+		apiWrite(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%v not allowed", r.Method)})
+	}
+}
+
+func apiGetBreakpoints(r *http.Request) (any, error) {
+	machine.Lock()
+	defer machine.Unlock()
+	list := make([]int, 0, len(breakpoints))
+	for addr := range breakpoints {
+		list = append(list, int(addr))
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+func apiSetBreakpoint(r *http.Request, set bool) (any, error) {
+	addr, err := apiAddr(r, "addr")
+	if err != nil {
+		return nil, err
+	}
+	machine.Lock()
+	if set {
+		breakpoints[addr] = true
+	} else {
+		delete(breakpoints, addr)
+	}
+	machine.Unlock()
+	return apiGetBreakpoints(r)
+}
+
+func apiWatchpointsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiRun(w, r, func(r *http.Request) (any, error) {
+			return listWatches(), nil
+		})
+	case http.MethodPost, http.MethodPut:
+		apiRun(w, r, apiAddWatch)
+	case http.MethodDelete:
+		apiRun(w, r, apiRemoveWatch)
+	default:
+		apiWrite(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%v not allowed", r.Method)})
+	}
+}
+
+func apiAddWatch(r *http.Request) (any, error) {
+	start, err := apiAddr(r, "addr")
+	if err != nil {
+		return nil, err
+	}
+	end := start
+	if len(r.URL.Query().Get("end")) > 0 {
+		if end, err = apiAddr(r, "end"); err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("end $%04x is before addr $%04x", end, start)
+		}
+	}
+	access := IO.Write
+	if s := r.URL.Query().Get("access"); len(s) > 0 {
+		a, ok := IO.ParseAccess(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid access %q, expected r, w and/or x", s)
+		}
+		access = a
+	}
+	return *addWatch(access, start, end), nil
+}
+
+func apiRemoveWatch(r *http.Request) (any, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q", r.URL.Query().Get("id"))
+	}
+	if !removeWatch(id) {
+		return nil, fmt.Errorf("no watchpoint %v", id)
+	}
+	return listWatches(), nil
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"strconv"
+	"strings"
 
 	"github.com/zoul0813/go6502/pkg/CPU"
 	"github.com/zoul0813/go6502/pkg/IO"
@@ -44,15 +44,100 @@ func DebugConsole(cpu *CPU.CPU, io *IO.IO) {
 		case "mem":
 			var start uint16 = 0x00
 			var end uint16 = 0xFF
+			bank := -1
 			if len(arg1) > 0 {
-				s, _ := strconv.ParseInt(arg1, 16, 16)
-				start = uint16(s)
+				var err error
+				bank, start, err = parseBankAddr(arg1)
+				if err != nil {
+					fmt.Printf("%v\n", err)
+					continue
+				}
 			}
 			if len(arg2) > 0 {
 				e, _ := strconv.ParseInt(arg2, 16, 16)
 				end = uint16(e)
 			}
-			io.Dump(start, end)
+			if bank >= 0 {
+				io.DumpBank(bank, start, end)
+			} else {
+				io.Dump(start, end)
+			}
+		case "b":
+			fallthrough
+		case "banks":
+			for _, d := range io.Devices {
+				if b, ok := d.Chip.(IO.Banked); ok {
+					fmt.Printf("%v ($%04x): bank %v of %v\n", d.Name, d.Offset, b.Active(), b.Banks())
+				}
+			}
+		case "w":
+			fallthrough
+		case "watch":
+			if len(arg1) == 0 {
+				for _, w := range listWatches() {
+					fmt.Printf("%v: $%04x-$%04x %v, %v hits\n", w.Id, w.Start, w.End, w.Access, w.Hits)
+				}
+				continue
+			}
+			start, end, err := parseRange(arg1)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			access := IO.Write
+			if len(arg2) > 0 {
+				a, ok := IO.ParseAccess(arg2)
+				if !ok {
+					fmt.Printf("invalid access %q, expected r, w and/or x\n", arg2)
+					continue
+				}
+				access = a
+			}
+			w := addWatch(access, start, end)
+			fmt.Printf("Watchpoint %v: $%04x-$%04x %v\n", w.Id, start, end, w.Access)
+		case "uw":
+			fallthrough
+		case "unwatch":
+			id, _ := strconv.Atoi(arg1)
+			if !removeWatch(id) {
+				fmt.Printf("no watchpoint %q\n", arg1)
+			}
+		case "i":
+			fallthrough
+		case "irq":
+			if len(arg1) == 0 {
+				sources := listInterrupts()
+				if len(sources) == 0 {
+					fmt.Printf("Nothing is wired to IRQ or NMI\n")
+				}
+				for _, s := range sources {
+					state := "released"
+					if s.Asserted {
+						state = "asserted"
+					}
+					brk := ""
+					if irqBreaks[s.Name] {
+						brk = ", break"
+					}
+					fmt.Printf("%v: %v %v, fired %v times%v\n", s.Name, lineName(&s), state, s.Count, brk)
+				}
+				continue
+			}
+			on, err := breakOnInterrupt(arg1)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			fmt.Printf("Break on %v = %v\n", arg1, on)
+		case "t":
+			fallthrough
+		case "tape":
+			s, err := tapeCommand(arg1, arg2)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			fmt.Printf("Tape: %v\n", s)
 		case "d":
 			fallthrough
 		case "debug":
@@ -76,6 +161,14 @@ func DebugConsole(cpu *CPU.CPU, io *IO.IO) {
 			fmt.Printf("zp|zeropage           mem dump of zero page\n")
 			fmt.Printf("s|stack               show stack ($0100:$1FF)\n")
 			fmt.Printf("m|mem [start, len]    show memory ($start..$len)\n")
+			fmt.Printf("m|mem [bank:start]    show memory in a bank, e.g. mem 2:8000\n")
+			fmt.Printf("b|banks               show the active banks\n")
+			fmt.Printf("w|watch [addr [rwx]]  stop on access to addr or start-end, w by default\n")
+			fmt.Printf("uw|unwatch id         remove a watchpoint\n")
+			fmt.Printf("i|irq [source]        show the interrupt sources, or toggle\n")
+			fmt.Printf("                      stopping when source fires\n")
+			fmt.Printf("t|tape [cmd [file]]   tape deck: insert file, eject, rewind, play, stop,\n")
+			fmt.Printf("                      record file, fast [on|off], list\n")
 			fmt.Printf("d|debug               print registers\n")
 			fmt.Printf("db|debug:bit          print registers as bits\n")
 			fmt.Printf("ss|singlestep         toggle single step\n")
@@ -84,7 +177,7 @@ func DebugConsole(cpu *CPU.CPU, io *IO.IO) {
 		case "q":
 			fallthrough
 		case "quit":
-			os.Exit(0)
+			exit(0)
 		case "test":
 			// special command for just doing quick tests
 			// code is volatile
@@ -105,3 +198,38 @@ func DebugConsole(cpu *CPU.CPU, io *IO.IO) {
 exitDebugConsole:
 	fmt.Printf("\n")
 }
+
+// parseBankAddr reads a hex address with an optional bank, "8000" or
+// "2:8000", bank is -1 when there isn't one
+func parseBankAddr(s string) (int, uint16, error) {
+	bank := -1
+	if b, a, found := strings.Cut(s, ":"); found {
+		n, err := strconv.Atoi(b)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid bank %q", b)
+		}
+		bank = n
+		s = a
+	}
+	addr, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid address %q", s)
+	}
+	return bank, uint16(addr), nil
+}
+
+// parseRange reads a hex address or a start-end range
+func parseRange(s string) (uint16, uint16, error) {
+	a, b, found := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(a, 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid address %q", a)
+	}
+	end := start
+	if found {
+		if end, err = strconv.ParseUint(b, 16, 16); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid end address %q", b)
+		}
+	}
+	return uint16(start), uint16(end), nil
+}
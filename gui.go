@@ -0,0 +1,299 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	scale     = 4
+	fontSize  = 8
+	padding   = 32
+	frameRate = 60
+)
+
+var (
+	normalFont  font.Face
+	screenColor = color.RGBA{4, 101, 13, 20}
+
+	// sized from the display in runGUI
+	pixelWidth   int
+	pixelHeight  int
+	screenWidth  int
+	screenHeight int
+)
+
+type Game struct {
+	// runes   []rune
+	// text    string
+	// runes         []rune
+	keys          []ebiten.Key
+	counter       int
+	showRegisters bool
+	showZeroPage  bool
+	showWozIn     bool
+	showStack     bool
+	singleStep    bool
+	tape          string // deck status, refreshed a few times a second
+	// shader        *ebiten.Shader // Shaders appear to be voodoo magic?
+}
+
+func (g *Game) Update() error {
+	// Keyboard input
+	g.keys = inpututil.AppendJustPressedKeys(g.keys[:0])
+
+	for _, key := range g.keys {
+		switch key {
+		case ebiten.KeyF1:
+			g.showRegisters = !g.showRegisters
+		case ebiten.KeyF2:
+			g.showZeroPage = !g.showZeroPage
+		case ebiten.KeyF3:
+			g.showWozIn = !g.showWozIn
+		case ebiten.KeyF4:
+			g.showStack = !g.showStack
+		case ebiten.KeyF5:
+			s := io.DumpString(0x0000, 0xFFFF)
+			os.WriteFile("dump.txt", []byte(s), 0644)
+			return fmt.Errorf("quit")
+		case ebiten.KeyF7:
+			cpu.SingleStep = !cpu.SingleStep
+			g.singleStep = cpu.SingleStep
+			fmt.Printf("SingleStep: CPU: %v, Game: %v\n", cpu.SingleStep, g.singleStep)
+		case ebiten.KeyF8:
+			if !cpu.SingleStep {
+				continue
+			}
+			halted := step()
+			if halted {
+				fmt.Printf("Halted: %v", cpu)
+			}
+		case ebiten.KeyF9:
+			g.tapeCommand("toggle", "")
+		case ebiten.KeyF10:
+			g.tapeCommand("rewind", "")
+		case ebiten.KeyF11:
+			g.tapeCommand("record", time.Now().Format("tape-20060102-150405.aci"))
+		case ebiten.KeyF12:
+			g.tapeCommand("eject", "")
+		case ebiten.KeyHome:
+			requestReset()
+		case ebiten.KeyEscape:
+			typeKey(0x1B) // ESC 27
+		case ebiten.KeyEnter:
+			typeKey(0x0D) // LF 10
+		case ebiten.KeyBackspace:
+			typeKey(0x08)
+		default:
+			var buffer []rune
+			buffer = ebiten.AppendInputChars(buffer[:0])
+			for _, r := range buffer {
+				typeKey(byte(r))
+			}
+			// fmt.Printf("KeyCode: %v, %v\n", key, buffer)
+			// name := ebiten.KeyName(key)
+			// fmt.Printf("KeyName: %v\n", name)
+			// if len(name) > 0 {
+			// 	b := name[0]
+			// 	fmt.Printf("Key: %02x %08b '%v'\n", b, b, string(b))
+			// 	keyboard.AppendKey(b)
+			// }
+		}
+	}
+
+	// drop a WAV file or tape image on the window to put it in the deck
+	if files := ebiten.DroppedFiles(); files != nil && deck != nil {
+		g.insertTape(files)
+	}
+
+	if deck != nil && g.counter%(frameRate/4) == 0 {
+		g.tape, _ = tapeCommand("status", "")
+	}
+
+	g.counter++
+	return nil
+}
+
+func (g *Game) tapeCommand(cmd string, arg string) {
+	s, err := tapeCommand(cmd, arg)
+	if err != nil {
+		s = err.Error()
+	}
+	g.tape = s
+	fmt.Printf("Tape: %v\n", s)
+}
+
+func (g *Game) insertTape(files fs.FS) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	name := entries[0].Name()
+	data, err := fs.ReadFile(files, name)
+	if err == nil {
+		machine.Lock()
+		err = deck.Insert(name, data)
+		machine.Unlock()
+	}
+	if err != nil {
+		fmt.Printf("Tape: %v\n", err)
+		return
+	}
+	g.tapeCommand("status", "")
+}
+
+func (g *Game) Draw(screen *ebiten.Image) {
+	screen.Clear()
+
+	blink := false
+	if g.counter%frameRate < (frameRate / 2) {
+		blink = true
+	}
+	t := display.All(blink)
+
+	bound := text.BoundString(normalFont, "W")
+
+	x := 0
+	y := 0 + bound.Dy()*scale
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(x), float64(y))
+	op.Filter = ebiten.FilterNearest
+	op.ColorScale.ScaleWithColor(screenColor)
+	text.DrawWithOptions(screen, t, normalFont, op)
+
+	if g.showRegisters {
+		DebugRegister(screen, normalFont, bound)
+	}
+
+	if g.showZeroPage {
+		DebugMemory(0x00, 0xFF, screen, normalFont, bound)
+	}
+
+	if g.showStack {
+		DebugMemory(0x0100, 0xFF, screen, normalFont, bound)
+	}
+
+	if g.showWozIn {
+		DebugMemory(0x0200, 0xFF, screen, normalFont, bound)
+	}
+
+	if deck != nil && g.tape != "no tape" {
+		DebugTape(g.tape, screen, normalFont, bound)
+	}
+}
+
+func DebugTape(s string, screen *ebiten.Image, font font.Face, bound image.Rectangle) {
+	dScale := 2.0
+	y := float64(screenHeight) - float64(bound.Dy())*dScale
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(dScale, dScale)
+	op.GeoM.Translate(0, y)
+	op.Filter = ebiten.FilterNearest
+	clr := color.RGBA{0, 110, 62, 20}
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(screen, "TAPE "+s, font, op)
+}
+
+func DebugMemory(start uint16, size uint16, screen *ebiten.Image, font font.Face, bound image.Rectangle) {
+	s := io.DumpString(start, size)
+
+	dScale := 2.0
+	x := float64(bound.Dx())
+	y := float64(bound.Dy())
+	x = float64(screenWidth) - ((x * dScale) * 55) // width of string
+	y = ((y * dScale) * 4)                         // number of lines
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(dScale, dScale)
+	op.GeoM.Translate(float64(x), float64(y))
+	op.Filter = ebiten.FilterNearest
+	clr := color.RGBA{0, 110, 62, 20}
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(screen, s, font, op)
+}
+
+func DebugRegister(screen *ebiten.Image, font font.Face, bound image.Rectangle) {
+	s := cpu.RegisterString()
+
+	dScale := 2.0
+	x := float64(bound.Dx())
+	y := float64(bound.Dy())
+	x = float64(screenWidth) - ((x * dScale) * 50) // width of string
+	y = float64(screenHeight) - ((y * dScale) * 4) // number of lines
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(dScale, dScale)
+	op.GeoM.Translate(float64(x), float64(y))
+	op.Filter = ebiten.FilterNearest
+	clr := color.RGBA{0, 110, 62, 20}
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(screen, s, font, op)
+}
+
+func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return screenWidth, screenHeight
+}
+
+func runGUI(singleStep bool) {
+	g := &Game{
+		// text:    "GO6502\nv0.0.0\n\n% ",
+		counter:       0,
+		showRegisters: false,
+		showZeroPage:  false,
+		showWozIn:     false,
+		singleStep:    singleStep,
+	}
+
+	fontFile, err := os.ReadFile("assets/fonts/C64_Pro_Mono-STYLE.ttf")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fontFace, err := sfnt.Parse(fontFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	const dpi = 72
+
+	normalFont, err = opentype.NewFace(fontFace, &opentype.FaceOptions{
+		Size:    8,
+		DPI:     dpi,
+		Hinting: font.HintingVertical,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cols, rows := display.Dimensions()
+	pixelWidth = fontSize * cols
+	pixelHeight = fontSize * rows
+	screenWidth = pixelWidth * scale
+	screenHeight = pixelHeight * scale
+
+	ebiten.SetWindowSize(screenWidth+padding, screenHeight+padding)
+	ebiten.SetWindowTitle("Gosho-1 (Apple 1 Emulator in Go)")
+	ebiten.SetTPS(frameRate)
+
+	if !singleStep {
+		go processTicks()
+	}
+
+	if err := ebiten.RunGame(g); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+)
+
+// headless exit codes
+const (
+	exitTrap   = 0 // PC reached the trap address
+	exitError  = 1
+	exitHalt   = 2 // CPU executed a DEBUG (halt) instruction
+	exitCycles = 3 // cycle limit reached
+	exitFault  = 4 // unmapped access under -unmapped fault
+)
+
+func runHeadless(inputFile string, trap int, maxCycles uint64) int {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	display.Listen(func(c byte) {
+		if c == 0x0D {
+			out.WriteByte('\n')
+			out.Flush()
+			return
+		}
+		out.WriteByte(c)
+	})
+
+	if player == nil && len(inputFile) > 0 {
+		in := os.Stdin
+		if inputFile != "-" {
+			f, err := os.Open(inputFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Input Error: %v\n", err)
+				return exitError
+			}
+			defer f.Close()
+			in = f
+		}
+		go readInput(in)
+	}
+
+	start := time.Now()
+	code := exitError
+	for {
+		if trap >= 0 && cpu.PC == uint16(trap) {
+			code = exitTrap
+			break
+		}
+		if maxCycles > 0 && cpu.Cycles >= maxCycles {
+			code = exitCycles
+			break
+		}
+		if paused() {
+			// paused by a breakpoint or the control API
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if step() {
+			code = exitHalt
+			break
+		}
+		if faulted {
+			code = exitFault
+			break
+		}
+	}
+	out.Flush()
+
+	elapsed := time.Since(start)
+	mhz := float64(cpu.Cycles) / elapsed.Seconds() / 1_000_000
+	fmt.Fprintf(os.Stderr, "\nStopped at $%04x after %v cycles in %v, %.2f MHz (exit %v)\n", cpu.PC, cpu.Cycles, elapsed, mhz, code)
+	return code
+}
+
+// readInput types everything read from in on the keyboard, a CR LF pair
+// counts as a single return
+func readInput(in *os.File) {
+	r := bufio.NewReader(in)
+	var prev byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == '\n' && prev == '\r' {
+			prev = b
+			continue
+		}
+		prev = b
+		if b == '\r' {
+			b = '\n'
+		}
+		typeKey(b)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/zoul0813/go6502/pkg/Keyboard"
+	"github.com/zoul0813/go6502/pkg/Replay"
+)
+
+var (
+	inputMutex sync.Mutex
+	inputQueue []byte
+	recorder   *Replay.Recorder
+	player     *Replay.Player
+)
+
+// typeKey queues a key press from the host, translated for the Apple-1 by
+// Keyboard.Translate.  It reaches the keyboard at the next instruction
+// boundary so the CPU cycle it arrives on is well defined.
+func typeKey(key byte) {
+	inputMutex.Lock()
+	defer inputMutex.Unlock()
+	if player != nil {
+		// live input would break the replay
+		return
+	}
+	inputQueue = append(inputQueue, Keyboard.Translate(key))
+}
+
+// feedKeys hands queued (or replayed) keys to the keyboard, it must only be
+// called between cpu.Step calls
+func feedKeys() {
+	inputMutex.Lock()
+	defer inputMutex.Unlock()
+
+	if player != nil {
+		for _, key := range player.Due(cpu.Cycles) {
+			keyboard.AppendKey(key)
+		}
+		if player.Done() {
+			fmt.Fprintf(os.Stderr, "Replay finished at cycle %v\n", cpu.Cycles)
+			player = nil
+		}
+		return
+	}
+
+	for _, key := range inputQueue {
+		keyboard.AppendKey(key)
+		if recorder != nil {
+			if err := recorder.Record(cpu.Cycles, key); err != nil {
+				fmt.Fprintf(os.Stderr, "Record Error: %v\n", err)
+			}
+		}
+	}
+	inputQueue = inputQueue[:0]
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+// irqBreaks are the interrupt sources that stop the CPU when they fire, by
+// name
+var irqBreaks = make(map[string]bool)
+
+// interruptFired runs when a source starts asserting, inside step with the
+// machine lock held
+func interruptFired(s *IO.Source) {
+	if !irqBreaks[s.Name] || cpu.SingleStep {
+		return
+	}
+	cpu.SingleStep = true
+	fmt.Printf("Interrupt from %v on %v, PC %v\n", s.Name, lineName(s), where(cpu.PC))
+}
+
+// breakOnInterrupt toggles stopping when the named source fires, the
+// machine lock must not be held
+func breakOnInterrupt(name string) (bool, error) {
+	machine.Lock()
+	defer machine.Unlock()
+	if board.Interrupts.Find(name) == nil {
+		return false, fmt.Errorf("no interrupt source %q", name)
+	}
+	irqBreaks[name] = !irqBreaks[name]
+	return irqBreaks[name], nil
+}
+
+// listInterrupts copies the interrupt sources, the machine lock must not
+// be held
+func listInterrupts() []IO.Source {
+	machine.Lock()
+	defer machine.Unlock()
+	list := make([]IO.Source, 0)
+	for _, s := range board.Interrupts.Sources() {
+		list = append(list, *s)
+	}
+	return list
+}
+
+func lineName(s *IO.Source) string {
+	if s.NMI {
+		return "NMI"
+	}
+	return "IRQ"
+}
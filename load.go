@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zoul0813/go6502/pkg/Loader"
+)
+
+// a -load argument, file or file@addr
+type loadFile struct {
+	path string
+	addr int // -1 when the file has to say
+}
+
+var loadFiles []loadFile
+
+func addLoadFile(s string) error {
+	l := loadFile{path: s, addr: -1}
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		a, err := strconv.ParseUint(strings.TrimPrefix(s[i+1:], "$"), 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid load address %q", s[i+1:])
+		}
+		l.path = s[:i]
+		l.addr = int(a)
+	}
+	loadFiles = append(loadFiles, l)
+	return nil
+}
+
+// loadAll writes every -load file into memory, format is forced when it
+// isn't "".  It returns the entry point of the last file that has one, or
+// nil.
+func loadAll(format Loader.Format) (*uint16, error) {
+	var entry *uint16
+	for _, l := range loadFiles {
+		f := format
+		if len(f) == 0 {
+			f = Loader.Detect(l.path)
+		}
+		if f == Loader.Binary && l.addr < 0 {
+			return nil, fmt.Errorf("%v: a raw binary needs an address, -load %v@0280", l.path, l.path)
+		}
+		if f != Loader.Binary && l.addr >= 0 {
+			return nil, fmt.Errorf("%v: %v files carry their own addresses", l.path, f)
+		}
+
+		img, err := Loader.Load(l.path, f, uint16(l.addr))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range img.Segments {
+			if _, err := io.LoadAt(s.Addr, s.Data); err != nil {
+				return nil, fmt.Errorf("%v at $%04x: %v", l.path, s.Addr, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Loaded %v bytes from %v\n", img.Size(), l.path)
+		if img.Entry != nil {
+			entry = img.Entry
+		}
+	}
+	return entry, nil
+}
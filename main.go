@@ -1,193 +1,165 @@
 package main
 
 import (
+	_ "embed"
 	"flag"
 	"fmt"
-	"image"
-	"image/color"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/zoul0813/go6502/pkg/ACI"
 	"github.com/zoul0813/go6502/pkg/CPU"
 	"github.com/zoul0813/go6502/pkg/Display"
 	"github.com/zoul0813/go6502/pkg/IO"
 	"github.com/zoul0813/go6502/pkg/Keyboard"
-	"github.com/zoul0813/go6502/pkg/Memory"
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/opentype"
-	"golang.org/x/image/font/sfnt"
+	"github.com/zoul0813/go6502/pkg/Loader"
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/Machine"
+	"github.com/zoul0813/go6502/pkg/Replay"
+	"github.com/zoul0813/go6502/pkg/Symbols"
 )
 
+// the machine used when -machine isn't given
+//
+//go:embed machines/apple1.json
+var defaultMachine []byte
+
 const (
-	ROM_HEAD            = 0x8000
-	ZP_HEAD             = 0x000
-	STACK_HEAD          = 0x100
-	SCREEN_HEAD  uint16 = 0x400
-	scale               = 4
-	cols                = 40
-	rows                = 25
-	fontSize            = 8
-	padding             = 32
-	frameRate           = 60
-	pixelWidth          = (fontSize * cols)
-	pixelHeight         = (fontSize * rows)
-	screenWidth         = pixelWidth * scale
-	screenHeight        = pixelHeight * scale
+	ROM_HEAD           = 0x8000
+	ZP_HEAD            = 0x000
+	STACK_HEAD         = 0x100
+	SCREEN_HEAD uint16 = 0x400
 )
 
 var (
-	normalFont font.Face
 	io         *IO.IO
 	clockSpeed = time.Nanosecond * 1000 // 1Mhz
 	// clockSpeed = time.Nanosecond * 10000 // 100Hz?
 	// TODO: make the clockSpeed variable with an argunment
 	// clockSpeed  = time.Millisecond * 100 // 10Hz
-	cpu         *CPU.CPU
-	ram         *Memory.Memory
-	keyboard    *Keyboard.Keyboard
-	display     *Display.Display
-	rom         *Memory.Memory
-	screenColor = color.RGBA{4, 101, 13, 20}
+	cpu      *CPU.CPU
+	keyboard *Keyboard.Keyboard
+	display  *Display.Display
+	config   *Machine.Config
+	board    *Machine.Machine
+
+	logger = Log.For("main")
+
+	resetPending atomic.Bool
+
+	// machine is held while an instruction runs, lock it to look at or
+	// change the machine from another goroutine
+	machine     sync.Mutex
+	breakpoints = make(map[uint16]bool)
+	faulted     bool // set by busFault
+	symbols     *Symbols.Table
+	cleanups    []func()
+	deck        *ACI.Deck // nil without an ACI
 )
 
-type Game struct {
-	// runes   []rune
-	// text    string
-	// runes         []rune
-	keys          []ebiten.Key
-	counter       int
-	showRegisters bool
-	showZeroPage  bool
-	showWozIn     bool
-	showStack     bool
-	singleStep    bool
-	// shader        *ebiten.Shader // Shaders appear to be voodoo magic?
+// atExit runs f when the emulator quits, through exit or from main
+func atExit(f func()) {
+	cleanups = append(cleanups, f)
 }
 
-func (g *Game) Update() error {
-	// Keyboard input
-	g.keys = inpututil.AppendJustPressedKeys(g.keys[:0])
-
-	for _, key := range g.keys {
-		switch key {
-		case ebiten.KeyF1:
-			g.showRegisters = !g.showRegisters
-		case ebiten.KeyF2:
-			g.showZeroPage = !g.showZeroPage
-		case ebiten.KeyF3:
-			g.showWozIn = !g.showWozIn
-		case ebiten.KeyF4:
-			g.showStack = !g.showStack
-		case ebiten.KeyF5:
-			s := io.DumpString(0x0000, 0xFFFF)
-			os.WriteFile("dump.txt", []byte(s), 0644)
-			return fmt.Errorf("quit")
-		case ebiten.KeyF7:
-			cpu.SingleStep = !cpu.SingleStep
-			g.singleStep = cpu.SingleStep
-			fmt.Printf("SingleStep: CPU: %v, Game: %v\n", cpu.SingleStep, g.singleStep)
-		case ebiten.KeyF8:
-			if !cpu.SingleStep {
-				continue
-			}
-			halted, _ := cpu.Step(io)
-			if cpu.DebugMode {
-				cpu.Debug()
-			}
-			if halted {
-				fmt.Printf("Halted: %v", cpu)
-			}
-		case ebiten.KeyHome:
-			// reset
-		case ebiten.KeyEscape:
-			keyboard.AppendKey(0x1B) // ESC 27
-		case ebiten.KeyEnter:
-			keyboard.AppendKey(0x0D) // LF 10
-		default:
-			var buffer []rune
-			buffer = ebiten.AppendInputChars(buffer[:0])
-			for _, r := range buffer {
-				b := strings.ToUpper(string(byte(r)))[0]
-				fmt.Printf("Key: %02x %02x '%v' '%v'\n", r, b, b, string(b))
-				keyboard.AppendKey(b)
-			}
-			// fmt.Printf("KeyCode: %v, %v\n", key, buffer)
-			// name := ebiten.KeyName(key)
-			// fmt.Printf("KeyName: %v\n", name)
-			// if len(name) > 0 {
-			// 	b := name[0]
-			// 	fmt.Printf("Key: %02x %08b '%v'\n", b, b, string(b))
-			// 	keyboard.AppendKey(b)
-			// }
-		}
+func exit(code int) {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
 	}
-
-	g.counter++
-	return nil
+	os.Exit(code)
 }
 
-func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Clear()
+// requestReset resets the CPU at the next instruction boundary, it's safe
+// to call from any goroutine
+func requestReset() {
+	resetPending.Store(true)
+}
 
-	blink := false
-	if g.counter%frameRate < (frameRate / 2) {
-		blink = true
-	}
-	t := display.All(blink)
+// paused reports whether the CPU is single stepping, it's safe to call from
+// any goroutine
+func paused() bool {
+	machine.Lock()
+	defer machine.Unlock()
+	return cpu.SingleStep
+}
 
-	bound := text.BoundString(normalFont, "W")
+// setPaused switches single step on or off and returns what it was, it's
+// safe to call from any goroutine
+func setPaused(p bool) bool {
+	machine.Lock()
+	defer machine.Unlock()
+	was := cpu.SingleStep
+	cpu.SingleStep = p
+	return was
+}
 
-	x := 0
-	y := 0 + bound.Dy()*scale
+// resetCPU resets the devices and runs the reset sequence, then applies the machine's reset
+// override if it has one.  The machine lock must be held.
+func resetCPU() error {
+	board.Reset()
+	if err := cpu.Reset(io); err != nil {
+		return err
+	}
+	if config.CPU.Reset != nil {
+		cpu.PC = uint16(*config.CPU.Reset)
+	}
+	return nil
+}
 
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(scale, scale)
-	op.GeoM.Translate(float64(x), float64(y))
-	op.Filter = ebiten.FilterNearest
-	op.ColorScale.ScaleWithColor(screenColor)
-	text.DrawWithOptions(screen, t, normalFont, op)
+// step runs a single instruction, handing pending input and resets to the
+// machine first.  Reaching a breakpoint switches to single step.
+func step() bool {
+	machine.Lock()
+	defer machine.Unlock()
 
-	if g.showRegisters {
-		cpu.DebugRegister(screen, normalFont, bound, screenHeight, screenWidth)
+	if resetPending.Swap(false) {
+		resetCPU()
 	}
-
-	if g.showZeroPage {
-		DebugMemory(0x00, 0xFF, screen, normalFont, bound)
+	feedKeys()
+	if deck != nil {
+		deck.Trap(cpu, io)
 	}
-
-	if g.showStack {
-		DebugMemory(0x0100, 0xFF, screen, normalFont, bound)
+	before := cpu.Cycles
+	halted, _ := cpu.Step(io)
+	board.Tick(cpu.Cycles - before)
+	if cpu.DebugMode {
+		cpu.Debug()
+	}
+	if breakpoints[cpu.PC] && !cpu.SingleStep {
+		cpu.SingleStep = true
+		fmt.Printf("Breakpoint at %v\n", where(cpu.PC))
 	}
+	return halted
+}
 
-	if g.showWozIn {
-		DebugMemory(0x0200, 0xFF, screen, normalFont, bound)
+// busFault stops the CPU on an unmapped access under the fault policy, it's
+// called from inside step so the machine lock is already held
+func busFault(addr uint16, write bool) {
+	access := "read from"
+	if write {
+		access = "write to"
 	}
+	cpu.SingleStep = true
+	faulted = true
+	fmt.Printf("Bus fault: %v unmapped $%04x, PC %v\n", access, addr, where(io.PC()))
 }
 
-func DebugMemory(start uint16, size uint16, screen *ebiten.Image, font font.Face, bound image.Rectangle) {
-	s := io.DumpString(start, size)
-
-	dScale := 2.0
-	x := float64(bound.Dx())
-	y := float64(bound.Dy())
-	x = float64(screenWidth) - ((x * dScale) * 55) // width of string
-	y = ((y * dScale) * 4)                         // number of lines
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(dScale, dScale)
-	op.GeoM.Translate(float64(x), float64(y))
-	op.Filter = ebiten.FilterNearest
-	clr := color.RGBA{0, 110, 62, 20}
-	op.ColorScale.ScaleWithColor(clr)
-	text.DrawWithOptions(screen, s, font, op)
+// uninitRead reports a read of RAM nothing has written, see -uninit
+func uninitRead(addr uint16) {
+	fmt.Fprintf(os.Stderr, "Uninitialized read of $%04x, PC %v\n", addr, where(io.PC()))
 }
 
-func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+// where formats an address with the nearest symbol, "$FF21 (GETLINE+2)"
+func where(addr uint16) string {
+	if name := symbols.Describe(addr); len(name) > 0 {
+		return fmt.Sprintf("$%04x (%v)", addr, name)
+	}
+	return fmt.Sprintf("$%04x", addr)
 }
 
 func processTicks() {
@@ -199,10 +171,7 @@ func processTicks() {
 		if cpu.SingleStep {
 			continue
 		}
-		halted, _ := cpu.Step(io)
-		if cpu.DebugMode {
-			cpu.Debug()
-		}
+		halted := step()
 		if halted {
 			cpu.SingleStep = true
 			fmt.Printf("Halted: %v", cpu)
@@ -211,7 +180,7 @@ func processTicks() {
 }
 
 func main() {
-	fmt.Printf("Go 6502... \n")
+	logger.Infof("Go 6502...")
 
 	// set clockSpeed to value of CLI argument
 	clockMultiplier := 1000 // 1000Khz
@@ -222,8 +191,92 @@ func main() {
 	flag.BoolVar(&singleStep, "single", false, "Single Step")
 	flag.BoolVar(&debugMode, "debug", false, "Debug Mode")
 	flag.BoolVar(&hz, "hz", false, "Set Clock to Hz")
+	recordFile := ""
+	replayFile := ""
+	flag.StringVar(&recordFile, "record", "", "Record keyboard input to file")
+	flag.StringVar(&replayFile, "replay", "", "Replay keyboard input from file")
+	headless := false
+	inputFile := "-"
+	trapAddr := ""
+	var maxCycles uint64
+	flag.BoolVar(&headless, "headless", false, "Run without a window, display output goes to stdout")
+	flag.StringVar(&inputFile, "input", "-", "Headless keyboard input file (- for stdin)")
+	flag.StringVar(&trapAddr, "trap", "", "Headless: exit when PC reaches this address (hex)")
+	flag.Uint64Var(&maxCycles, "cycles", 0, "Headless: exit after this many CPU cycles (0 = no limit)")
+	termMode := false
+	flag.BoolVar(&termMode, "term", false, "Use the terminal (ANSI) instead of a window")
+	listenAddr := ""
+	listenMode := "shared"
+	listenRaw := false
+	flag.StringVar(&listenAddr, "listen", "", "Serve the terminal over telnet on this address (e.g. :6502)")
+	flag.StringVar(&listenMode, "listen-mode", "shared", "Telnet input mode, shared or exclusive")
+	flag.BoolVar(&listenRaw, "listen-raw", false, "Serve raw TCP, without telnet negotiation")
+	apiAddr := ""
+	flag.StringVar(&apiAddr, "api", "", "Serve the HTTP/JSON control API on this address (e.g. :6580)")
+	webAddr := ""
+	flag.StringVar(&webAddr, "web", "", "Serve the browser front end on this address (e.g. :8080)")
+	logSpec := ""
+	flag.StringVar(&logSpec, "log", "", "Enable logging, comma separated category[=level] (e.g. io,keyboard=info or all)")
+	machineFile := ""
+	flag.StringVar(&machineFile, "machine", "", "Machine config file (default: the built in Apple-1)")
+	unmapped := ""
+	flag.StringVar(&unmapped, "unmapped", "", "Unmapped reads and writes: openbus, fixed:XX or fault (default: the machine's)")
+	uninit := false
+	symbolFile := ""
+	ramFill := ""
+	flag.BoolVar(&uninit, "uninit", false, "Report reads of RAM that hasn't been written since power on")
+	flag.StringVar(&symbolFile, "symbols", "", "Label file from ld65 -Ln, for naming addresses in reports")
+	flag.Func("load", "Load a program, hex, srec, woz or bin (bin needs file@addr), can be repeated", addLoadFile)
+	loadFormat := ""
+	runAddr := ""
+	flag.StringVar(&runAddr, "run", "", "Start at this address (hex) instead of the reset vector or a loaded file's entry point")
+	flag.StringVar(&loadFormat, "load-format", "", "Format of the -load files, instead of going by extension")
+	tapeIn := ""
+	tapeOut := ""
+	tapeFast := false
+	flag.StringVar(&tapeIn, "tape-in", "", "Play this WAV file or tape image into the ACI's tape input")
+	flag.StringVar(&tapeOut, "tape-out", "", "Record the ACI's tape output to this WAV file, or a tape image for any other extension")
+	flag.BoolVar(&tapeFast, "tape-fast", false, "Load and save tape images without the audio")
+	flag.Func("serial", "Host end of a serial port, [NAME=]pty, stdio or tcp:ADDR (default: the machine's), can be repeated", addSerialPort)
+	flag.StringVar(&ramFill, "ram-fill", "", "RAM at power on: zero, random, random:seed or a hex pattern (default: the machine's)")
 	flag.Parse()
 
+	if err := Log.Enable(logSpec); err != nil {
+		log.Fatal(err)
+	}
+
+	if headless && termMode {
+		log.Fatal("-headless and -term can't be used together")
+	}
+
+	trap := -1
+	if len(trapAddr) > 0 {
+		t, err := strconv.ParseUint(trapAddr, 16, 16)
+		if err != nil {
+			log.Fatalf("invalid trap address %q: %v", trapAddr, err)
+		}
+		trap = int(t)
+	}
+
+	var err error
+	if len(machineFile) > 0 {
+		config, err = Machine.Load(machineFile)
+	} else {
+		config, err = Machine.Parse(defaultMachine)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the machine's clock, unless -clock was given
+	clockSet := false
+	flag.Visit(func(f *flag.Flag) {
+		clockSet = clockSet || f.Name == "clock"
+	})
+	if !clockSet && config.CPU.Clock > 0 {
+		clockMultiplier = config.CPU.Clock
+	}
+
 	// calculate the clock speed using kHz
 	khz := time.Microsecond * 1_000
 	if hz {
@@ -231,43 +284,77 @@ func main() {
 	}
 	d := khz / time.Duration(clockMultiplier)
 	clockSpeed = time.Nanosecond * d
-	fmt.Printf("Clock Mult : %v (%v)\n", clockMultiplier, d)
-	fmt.Printf("Clock Speed: %v\n", clockSpeed)
-
-	// RAM
-	ram = Memory.New(0x8000, 0x0000, false)
-	// ram = Memory.New(0xFFFF, 0x0000, false)
-
-	// Keyboard
-	keyboard = Keyboard.New(0xD010)
+	logger.Infof("clock mult: %v (%v), speed: %v", clockMultiplier, d, clockSpeed)
 
-	// Display
-	display = Display.New(0xD012, cols, rows)
+	if len(recordFile) > 0 && len(replayFile) > 0 {
+		log.Fatal("-record and -replay can't be used together")
+	}
+	if len(recordFile) > 0 {
+		recorder, err = Replay.NewRecorder(recordFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer recorder.Close()
+		fmt.Fprintf(os.Stderr, "Recording input to %v\n", recordFile)
+	}
+	if len(replayFile) > 0 {
+		player, err = Replay.Load(replayFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Replaying %v keys from %v\n", player.Len(), replayFile)
+	}
 
-	// ROM
-	rom = Memory.New(0x1000, 0xF000, true)
-	f, err := os.ReadFile("rom/rom.bin")
+	if len(ramFill) > 0 {
+		for i := range config.Memory {
+			config.Memory[i].Fill = ramFill
+		}
+	}
+	m, err := config.Build()
 	if err != nil {
-		fmt.Printf("Can't read file rom/rom.bin\n\n")
-		panic(err)
+		log.Fatal(err)
+	}
+	if m.Keyboard == nil || m.Display == nil {
+		log.Fatalf("%v: the machine needs a keyboard and a display", config.Name)
+	}
+	board = m
+	io = m.IO
+	keyboard = m.Keyboard
+	display = m.Display
+	logger.Infof("machine: %v", config.Name)
+
+	if len(unmapped) > 0 {
+		if io.Unmapped, err = IO.ParseUnmapped(unmapped); err != nil {
+			log.Fatal(err)
+		}
 	}
+	io.OnFault = busFault
 
-	devices := []*IO.Device{
-		IO.NewDevice("RAM", ram, 0x0000),
-		IO.NewDevice("Keyboard", keyboard, 0xD010),
-		IO.NewDevice("Display", display, 0xD012),
-		IO.NewDevice("ROM", rom, 0xF000),
+	if len(symbolFile) > 0 {
+		if symbols, err = Symbols.Load(symbolFile); err != nil {
+			log.Fatal(err)
+		}
+		logger.Infof("loaded %v symbols from %v", symbols.Len(), symbolFile)
+	}
+	if uninit {
+		for _, mem := range m.RAM() {
+			mem.Track(uninitRead)
+		}
 	}
-	io = IO.New(devices)
 
-	fmt.Printf("Loading rom %04x (%v) bytes\n", len(f), len(f))
-	io.LoadRom(f, 0xF000)
 	io.Set(0x0000, 0x55)
 	io.Set(0x00FF, 0x33)
-	io.Dump(0xF000, 0xFF)
 
-	// fmt.Printf("Loading ram %04x (%v) bytes\n", len(f), len(f))
-	// io.LoadRom(f, 0x0000)
+	var format Loader.Format
+	if len(loadFormat) > 0 {
+		if format, err = Loader.ParseFormat(loadFormat); err != nil {
+			log.Fatal(err)
+		}
+	}
+	entry, err := loadAll(format)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	cpu = CPU.New(
 		0xfffc,     // PC
@@ -279,13 +366,97 @@ func main() {
 		singleStep, // Single Step
 		debugMode,  // DebugMode
 	)
+	cpu.Interrupts = m.Interrupts
+	m.Interrupts.Fired = interruptFired
 
 	word, _ := io.GetWord(cpu.PC)
 	cpu.PC = word
+	if config.CPU.Reset != nil {
+		cpu.PC = uint16(*config.CPU.Reset)
+	}
+	if len(runAddr) > 0 {
+		pc, err := strconv.ParseUint(strings.TrimPrefix(runAddr, "$"), 16, 16)
+		if err != nil {
+			log.Fatalf("invalid run address %q", runAddr)
+		}
+		cpu.PC = uint16(pc)
+	} else if entry != nil {
+		cpu.PC = *entry
+		fmt.Fprintf(os.Stderr, "Starting at $%04x\n", cpu.PC)
+	}
+
+	if hz {
+		m.SetClock(float64(clockMultiplier))
+	} else {
+		m.SetClock(float64(clockMultiplier) * 1000)
+	}
+
+	if m.ACI != nil {
+		deck = ACI.NewDeck(m.ACI)
+		deck.Fast = tapeFast
+		if len(tapeIn) > 0 && len(tapeOut) > 0 {
+			log.Fatal("there's one tape deck, use -tape-in or -tape-out")
+		}
+		if len(tapeIn) > 0 {
+			if err := deck.Open(tapeIn); err != nil {
+				log.Fatal(err)
+			}
+			deck.Play()
+		}
+		if len(tapeOut) > 0 {
+			if err := deck.Record(tapeOut); err != nil {
+				log.Fatal(err)
+			}
+		}
+		atExit(func() {
+			if err := deck.Stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "Tape Error: %v\n", err)
+			}
+		})
+	} else if len(tapeIn) > 0 || len(tapeOut) > 0 {
+		log.Fatalf("%v: -tape-in and -tape-out need an ACI", config.Name)
+	}
 
 	// fmt.Printf("ZeroPage: %04x bytes from %04x\n", 0xff, 0x0000)
 	// io.Dump(0x0000, 0xff) // Zero Page
 
+	stdio, err := startSerial()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if stdio && termMode {
+		log.Fatal("-term and a stdio serial port can't share the terminal")
+	}
+	if stdio && headless && inputFile == "-" {
+		// the serial port has stdin, the keyboard gets nothing
+		inputFile = ""
+	}
+
+	if len(listenAddr) > 0 {
+		if err := startTelnet(listenAddr, listenMode, listenRaw); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(apiAddr) > 0 {
+		if err := startAPI(apiAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(webAddr) > 0 {
+		if err := startWeb(webAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if headless {
+		exit(runHeadless(inputFile, trap, maxCycles))
+	}
+	if termMode {
+		exit(runTerminal())
+	}
+
 	fmt.Printf("\n\n")
 	// Reset Vectors
 	fmt.Printf("Reset: %04x bytes from %04x\n", 0x0f, 0xfff0)
@@ -301,45 +472,6 @@ func main() {
 
 	cpu.Debug()
 
-	g := &Game{
-		// text:    "GO6502\nv0.0.0\n\n% ",
-		counter:       0,
-		showRegisters: false,
-		showZeroPage:  false,
-		showWozIn:     false,
-		singleStep:    singleStep,
-	}
-
-	fontFile, err := os.ReadFile("assets/fonts/C64_Pro_Mono-STYLE.ttf")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	fontFace, err := sfnt.Parse(fontFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	const dpi = 72
-
-	normalFont, err = opentype.NewFace(fontFace, &opentype.FaceOptions{
-		Size:    8,
-		DPI:     dpi,
-		Hinting: font.HintingVertical,
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	ebiten.SetWindowSize(screenWidth+padding, screenHeight+padding)
-	ebiten.SetWindowTitle("Gosho-1 (Apple 1 Emulator in Go)")
-	ebiten.SetTPS(frameRate)
-
-	if !singleStep {
-		go processTicks()
-	}
-
-	if err := ebiten.RunGame(g); err != nil {
-		log.Fatal(err)
-	}
+	runGUI(singleStep)
+	exit(0)
 }
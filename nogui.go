@@ -0,0 +1,9 @@
+//go:build nogui
+
+package main
+
+import "log"
+
+func runGUI(singleStep bool) {
+	log.Fatal("built without GUI support (-tags nogui), use -headless")
+}
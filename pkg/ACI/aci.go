@@ -0,0 +1,203 @@
+package ACI
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Log"
+)
+
+var logger = Log.For("aci")
+
+/*
+	The Apple Cassette Interface, see docs/roms/wozaci.asm.
+
+	$C000-$C0FF  any access toggles the output flip-flop.  Reads return
+	             the PROM byte at the same offset in $C100, with A0
+	             replaced by the tape input, which is how TAPEIN ($C081)
+	             reads $C180 or $C181 depending on the level.
+	$C100-$C1FF  the PROM
+
+	Time comes from the machine's Clock, CPU cycles, so the audio runs at
+	the emulated speed however fast the emulator itself is going.
+*/
+
+type ACI struct {
+	rom    [256]byte
+	offset uint16
+	clock  *IO.Clock
+
+	out bool // output flip-flop
+
+	// recording
+	wav   *WavWriter
+	start uint64 // cycle of sample 0
+	last  bool   // level written up to wav.Samples()
+
+	// playback
+	tape    *Wav
+	pos     uint64 // sample playback starts from
+	playing bool
+	played  uint64 // cycle playback started at
+}
+
+func New(offset uint16, rom []byte, clock *IO.Clock) *ACI {
+	a := &ACI{
+		offset: offset,
+		clock:  clock,
+	}
+	copy(a.rom[:], rom)
+	return a
+}
+
+// Record writes the cassette output to w from now on
+func (a *ACI) Record(w *WavWriter) {
+	a.wav = w
+	a.start = a.cycles()
+	a.last = a.out
+}
+
+// StopRecording writes out the audio up to now and closes the WAV
+func (a *ACI) StopRecording() error {
+	if a.wav == nil {
+		return nil
+	}
+	a.catchUp()
+	err := a.wav.Close()
+	a.wav = nil
+	return err
+}
+
+// Play uses w as the tape input from sample pos, it starts the first time
+// the CPU looks at the input after this, like pressing play right after
+// typing "R"
+func (a *ACI) Play(w *Wav, pos uint64) {
+	a.tape = w
+	a.pos = pos
+	a.playing = false
+}
+
+// Stop takes the tape out of the input and returns the sample it got to
+func (a *ACI) Stop() uint64 {
+	pos := a.Position()
+	a.tape = nil
+	a.playing = false
+	return pos
+}
+
+// Position is the sample playback is at
+func (a *ACI) Position() uint64 {
+	if a.tape == nil {
+		return 0
+	}
+	if !a.playing {
+		return a.pos
+	}
+	return min(a.sample(), uint64(len(a.tape.Levels)))
+}
+
+// woz checks the PROM is Woz's, for the deck's fast paths
+func (a *ACI) woz() bool {
+	return a.rom[writeEntry] == 0xA9 && a.rom[writeEntry+1] == 0x40 && // LDA #64
+		a.rom[restIdx] == 0xA6 && a.rom[restIdx+1] == saveIndex && // LDX SAVEINDEX
+		a.rom[readEntry] == 0x20 && a.rom[readEntry+3] == 0xA9 // JSR FULLCYCLE, LDA #22
+}
+
+func (a *ACI) cycles() uint64 {
+	return a.clock.Now()
+}
+
+// catchUp writes samples at the current level up to now
+func (a *ACI) catchUp() {
+	n := uint64(float64(a.cycles()-a.start) * SampleRate / a.clock.Hz)
+	if have := uint64(a.wav.Samples()); n > have {
+		if err := a.wav.Write(a.last, int(n-have)); err != nil {
+			logger.Errorf("tape out: %v", err)
+		}
+	}
+}
+
+func (a *ACI) toggle() {
+	if a.wav != nil {
+		a.catchUp()
+	}
+	a.out = !a.out
+	a.last = a.out
+}
+
+// sample is where playback is, in samples
+func (a *ACI) sample() uint64 {
+	return a.pos + uint64(float64(a.cycles()-a.played)*float64(a.tape.Rate)/a.clock.Hz)
+}
+
+func (a *ACI) input() byte {
+	if a.tape == nil {
+		return 0
+	}
+	if !a.playing {
+		a.playing = true
+		a.played = a.cycles()
+		logger.Infof("tape in: playing from sample %v of %v", a.pos, len(a.tape.Levels))
+	}
+	s := a.sample()
+	if s >= uint64(len(a.tape.Levels)) {
+		return 0
+	}
+	if a.tape.Levels[s] {
+		return 1
+	}
+	return 0
+}
+
+// IO.Memory Interface
+func (a *ACI) Size() uint16 {
+	return 0x01FF
+}
+
+func (a *ACI) Get(addr uint16) (byte, error) {
+	o := addr - a.offset
+	if o < 0x100 {
+		a.toggle()
+		return a.rom[o&0xFE|uint16(a.input())], nil
+	}
+	return a.rom[o&0xFF], nil
+}
+
+func (a *ACI) GetWord(addr uint16) (uint16, error) {
+	lo, _ := a.Get(addr)
+	hi, _ := a.Get(addr + 1)
+	return uint16(hi)<<8 | uint16(lo), nil
+}
+
+func (a *ACI) Set(addr uint16, value byte) error {
+	if addr-a.offset < 0x100 {
+		a.toggle()
+		return nil
+	}
+	return fmt.Errorf("ACI: attempt to write to ROM at %04x", addr)
+}
+
+func (a *ACI) SetWord(addr uint16, value uint16) error {
+	if err := a.Set(addr, byte(value)); err != nil {
+		return err
+	}
+	return a.Set(addr+1, byte(value>>8))
+}
+
+func (a *ACI) Load(bytes []byte) (uint16, error) {
+	n := copy(a.rom[:], bytes)
+	return uint16(n), nil
+}
+
+// Peek reads without toggling the flip-flop, for dumps and the debugger
+func (a *ACI) Peek(addr uint16) (byte, error) {
+	return a.rom[(addr-a.offset)&0xFF], nil
+}
+
+// Poke writes the PROM, for loaders
+func (a *ACI) Poke(addr uint16, value byte) error {
+	if o := addr - a.offset; o >= 0x100 {
+		a.rom[o&0xFF] = value
+	}
+	return nil
+}
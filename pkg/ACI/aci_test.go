@@ -0,0 +1,184 @@
+package ACI
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/CPU"
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Memory"
+)
+
+// newACI is an ACI at $C000 on a 1MHz clock, so a cycle is a microsecond.
+// Its PROM reads back the tape input in bit 0, like $C081 does.
+func newACI() (*ACI, *IO.Clock) {
+	clock := IO.NewClock(1_000_000)
+	rom := make([]byte, 0x100)
+	rom[0x81] = 0x01
+	return New(0xC000, rom, clock), clock
+}
+
+// record writes a square wave with the given half periods, in
+// microseconds, through the ACI's output
+func record(t *testing.T, phases []int) string {
+	path := filepath.Join(t.TempDir(), "tape.wav")
+	w, err := CreateWav(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, clock := newACI()
+	a.Record(w)
+	for _, us := range phases {
+		clock.Advance(uint64(us))
+		a.Set(0xC000, 0)
+	}
+	clock.Advance(1000)
+	if err := a.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// play reads w through the ACI's input and returns the half periods it
+// sees, in microseconds
+func play(w *Wav, n int) []int {
+	a, clock := newACI()
+	a.Play(w, 0)
+	var phases []int
+	level, last := byte(0), uint64(0)
+	for len(phases) < n && clock.Now() < 10_000_000 {
+		v, _ := a.Get(0xC081)
+		if v&0x01 != level {
+			level = v & 0x01
+			phases = append(phases, int(clock.Now()-last))
+			last = clock.Now()
+		}
+		clock.Advance(5)
+	}
+	return phases
+}
+
+// same checks the half periods match to within a couple of samples
+func same(t *testing.T, got []int, want []int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("read %v half periods, wrote %v", len(got), len(want))
+	}
+	for i := range want {
+		if d := got[i] - want[i]; d < -50 || d > 50 {
+			t.Fatalf("half period %v is %vus, wrote %vus", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWavRoundTrip(t *testing.T) {
+	var phases []int
+	for i := 0; i < 20; i++ {
+		phases = append(phases, headerPhase)
+	}
+	// $A5 as the PROM writes it, MSB first, a full cycle a bit
+	for bit := 7; bit >= 0; bit-- {
+		us := zeroPhase
+		if 0xA5&(1<<bit) != 0 {
+			us = onePhase
+		}
+		phases = append(phases, us, us)
+	}
+
+	w, err := ReadWav(record(t, phases))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Rate != SampleRate {
+		t.Fatalf("rate %v, want %v", w.Rate, SampleRate)
+	}
+	same(t, play(w, len(phases)), phases)
+}
+
+func TestBlockAudio(t *testing.T) {
+	b := Block{Start: 0x0300, Data: []byte{0x00, 0xFF}}
+	w := b.Audio(SampleRate)
+
+	var want []int
+	for n := 0; n < headerTime; n += headerPhase {
+		want = append(want, headerPhase)
+	}
+	want = append(want, zeroPhase, zeroPhase)
+	for _, v := range b.Data {
+		for bit := 7; bit >= 0; bit-- {
+			us := zeroPhase
+			if v&(1<<bit) != 0 {
+				us = onePhase
+			}
+			want = append(want, us, us)
+		}
+	}
+	same(t, play(w, len(want)), want)
+}
+
+func TestTapeImage(t *testing.T) {
+	tape := &Tape{Blocks: []Block{
+		{Start: 0x0300, Data: []byte{0xA9, 0x00, 0x60}},
+		{Start: 0x0E00, Data: []byte{0x01}},
+	}}
+	data := tape.Bytes()
+	got, err := ParseTape(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Blocks) != len(tape.Blocks) {
+		t.Fatalf("read %v blocks, wrote %v", len(got.Blocks), len(tape.Blocks))
+	}
+	for i, b := range tape.Blocks {
+		if got.Blocks[i].Start != b.Start || !bytes.Equal(got.Blocks[i].Data, b.Data) {
+			t.Errorf("block %v is % X at $%04x, wrote % X at $%04x", i, got.Blocks[i].Data, got.Blocks[i].Start, b.Data, b.Start)
+		}
+	}
+
+	data[len(data)-1] ^= 0xFF
+	if _, err := ParseTape(data); err == nil {
+		t.Error("read a block with a bad checksum")
+	}
+	if _, err := ParseTape(data[:len(data)-2]); err == nil {
+		t.Error("read a truncated block")
+	}
+}
+
+// TestFastRead runs READ with an image in the deck, the block should land
+// where "R" asked and the CPU come out at RESTIDX
+func TestFastRead(t *testing.T) {
+	clock := IO.NewClock(1_000_000)
+	rom := make([]byte, 0x100)
+	// just enough of the Woz PROM for the deck to recognise it
+	copy(rom[writeEntry:], []byte{0xA9, 0x40})
+	copy(rom[restIdx:], []byte{0xA6, saveIndex})
+	copy(rom[readEntry:], []byte{0x20, 0x00, 0x00, 0xA9})
+	a := New(0xC000, rom, clock)
+	ram := Memory.New(0xBFFF, 0x0000, false)
+	io := IO.New([]*IO.Device{IO.NewDevice("RAM", ram, 0x0000)})
+
+	d := NewDeck(a)
+	d.Fast = true
+	tape := &Tape{Blocks: []Block{{Start: 0x0300, Data: []byte{1, 2, 3, 4}}}}
+	if err := d.Insert("tape.aci", tape.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Play(); err != nil {
+		t.Fatal(err)
+	}
+
+	io.SetWord(hex2, 0x0800) // 0800.0803R
+	io.SetWord(hex1, 0x0803)
+	cpu := CPU.New(0xC100+readEntry, 0xFF, 0, 0x05, 0, 0x30, false, false)
+	d.Trap(cpu, io)
+	if !bytes.Equal(ram.Bytes[0x0800:0x0804], []byte{1, 2, 3, 4}) {
+		t.Errorf("loaded % X, want 01 02 03 04", ram.Bytes[0x0800:0x0804])
+	}
+	if cpu.PC != 0xC100+restIdx {
+		t.Errorf("PC $%04x, want RESTIDX", cpu.PC)
+	}
+	if next, _ := io.GetWord(hex2); next != 0x0804 {
+		t.Errorf("address left at $%04x, want $0804", next)
+	}
+}
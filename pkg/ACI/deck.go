@@ -0,0 +1,291 @@
+package ACI
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zoul0813/go6502/pkg/CPU"
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+/*
+	The cassette deck plugged into the ACI.  A tape is a WAV recording,
+	played into TAPEIN as it is, or an image (see tape.go), played a block
+	for every "R" the PROM's READ routine is run for.
+
+	With Fast on, images skip the audio.  When the CPU gets to READ the
+	next block is copied straight into the range the "R" command asked for
+	and the CPU carries on after the read loop, the same goes for WRITE
+	when recording to an image.  That only works with the Woz PROM, any
+	other PROM always gets the audio.
+*/
+
+// where things are in the Woz PROM, see docs/roms/wozaci.asm
+const (
+	hex1      = 0x24 // end address
+	hex2      = 0x26 // current address
+	saveIndex = 0x28
+
+	writeEntry = 0x70 // WRITE, from the start of the PROM
+	restIdx    = 0x89 // RESTIDX, where READ and WRITE finish
+	readEntry  = 0x8D // READ
+)
+
+type deckState int
+
+const (
+	stopped deckState = iota
+	playing
+	recording
+)
+
+func (s deckState) String() string {
+	return [...]string{"stopped", "playing", "recording"}[s]
+}
+
+type Deck struct {
+	aci  *ACI
+	Fast bool // load and save images without the audio
+
+	name  string // the tape in the deck
+	wav   *Wav   // a recording
+	image *Tape  // or an image
+	state deckState
+
+	pos   uint64 // where the recording is, in samples
+	block int    // next block of the image
+}
+
+func NewDeck(aci *ACI) *Deck {
+	return &Deck{aci: aci}
+}
+
+// Insert puts data, a WAV file or a tape image, in the deck
+func (d *Deck) Insert(name string, data []byte) error {
+	var wav *Wav
+	var image *Tape
+	var err error
+	if IsWav(data) {
+		wav, err = ParseWav(data)
+	} else {
+		image, err = ParseTape(data)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %v", name, err)
+	}
+	d.Eject()
+	d.name = name
+	d.wav = wav
+	d.image = image
+	return nil
+}
+
+func (d *Deck) Open(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return d.Insert(path, data)
+}
+
+func (d *Deck) Eject() error {
+	err := d.Stop()
+	d.name = ""
+	d.wav = nil
+	d.image = nil
+	d.pos = 0
+	d.block = 0
+	return err
+}
+
+func (d *Deck) Rewind() error {
+	wasPlaying := d.state == playing
+	err := d.Stop()
+	d.pos = 0
+	d.block = 0
+	if wasPlaying {
+		return d.Play()
+	}
+	return err
+}
+
+func (d *Deck) Play() error {
+	if d.state == playing {
+		return nil
+	}
+	if d.wav == nil && d.image == nil {
+		return fmt.Errorf("no tape in the deck")
+	}
+	if err := d.Stop(); err != nil {
+		return err
+	}
+	d.state = playing
+	if d.wav != nil {
+		d.aci.Play(d.wav, d.pos)
+	}
+	return nil
+}
+
+// Record starts a new tape at path, a WAV file if it ends in .wav or an
+// image otherwise
+func (d *Deck) Record(path string) error {
+	if err := d.Eject(); err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		w, err := CreateWav(path)
+		if err != nil {
+			return err
+		}
+		d.aci.Record(w)
+	} else {
+		d.image = &Tape{}
+		if err := d.image.Save(path); err != nil {
+			d.image = nil
+			return err
+		}
+	}
+	d.name = path
+	d.state = recording
+	return nil
+}
+
+// Stop stops playing or recording, a finished WAV recording is left in the
+// deck ready to play back
+func (d *Deck) Stop() error {
+	state := d.state
+	d.state = stopped
+	switch state {
+	case playing:
+		if pos := d.aci.Stop(); d.wav != nil {
+			d.pos = pos
+		}
+	case recording:
+		if d.image != nil {
+			return d.image.Save(d.name)
+		}
+		if err := d.aci.StopRecording(); err != nil {
+			return err
+		}
+		wav, err := ReadWav(d.name)
+		if err != nil {
+			return err
+		}
+		d.wav = wav
+	}
+	return nil
+}
+
+// Running is true while playing or recording
+func (d *Deck) Running() bool {
+	return d.state != stopped
+}
+
+func (d *Deck) Status() string {
+	if len(d.name) == 0 {
+		return "no tape"
+	}
+	s := fmt.Sprintf("%v: %v", filepath.Base(d.name), d.state)
+	switch {
+	case d.image != nil:
+		s += fmt.Sprintf(", block %v of %v", min(d.block+1, len(d.image.Blocks)), len(d.image.Blocks))
+		if d.Fast {
+			s += ", fast"
+		}
+	case d.wav != nil:
+		pos := d.pos
+		if d.state == playing {
+			pos = d.aci.Position()
+		}
+		s += fmt.Sprintf(", %.1fs of %.1fs", float64(pos)/float64(d.wav.Rate), float64(len(d.wav.Levels))/float64(d.wav.Rate))
+	}
+	return s
+}
+
+// Blocks lists the blocks of an image
+func (d *Deck) Blocks() []Block {
+	if d.image == nil {
+		return nil
+	}
+	return d.image.Blocks
+}
+
+// Trap runs before every instruction, with the CPU at the one about to run,
+// and takes over from the PROM's READ and WRITE routines for images
+func (d *Deck) Trap(cpu *CPU.CPU, io *IO.IO) {
+	if d.image == nil || d.state == stopped {
+		return
+	}
+	prom := d.aci.offset + 0x100
+	switch {
+	case d.state == playing && cpu.PC == prom+readEntry && d.aci.woz():
+		d.read(cpu, io)
+	case d.state == recording && cpu.PC == prom+writeEntry && d.aci.woz():
+		d.write(cpu, io)
+	}
+}
+
+func (d *Deck) read(cpu *CPU.CPU, io *IO.IO) {
+	if d.block >= len(d.image.Blocks) {
+		logger.Infof("tape in: end of tape")
+		return
+	}
+	b := d.image.Blocks[d.block]
+	d.block++
+	if !d.Fast {
+		d.aci.Play(b.Audio(SampleRate), 0)
+		return
+	}
+
+	start, end := word(io, hex2), word(io, hex1)
+	n := int(end-start) + 1
+	if len(b.Data) < n {
+		logger.Warnf("tape in: block %v has %v bytes, $%04x.%04xR wants %v", d.block, len(b.Data), start, end, n)
+	}
+	for i := 0; i < n && i < len(b.Data); i++ {
+		io.Set(start+uint16(i), b.Data[i])
+	}
+	logger.Infof("tape in: block %v loaded at $%04x-$%04x", d.block, start, end)
+	d.skip(cpu, io, end)
+}
+
+func (d *Deck) write(cpu *CPU.CPU, io *IO.IO) {
+	start, end := word(io, hex2), word(io, hex1)
+	b := Block{Start: start}
+	for addr := int(start); addr <= int(end); addr++ {
+		v, _ := io.Peek(uint16(addr))
+		b.Data = append(b.Data, v)
+	}
+	if len(b.Data) == 0 {
+		// the PROM writes $10000 bytes wrapping around, nobody means it
+		logger.Warnf("tape out: $%04x.%04xW is backwards, not saved", start, end)
+	} else {
+		d.image.Blocks = append(d.image.Blocks, b)
+		d.block = len(d.image.Blocks)
+		if err := d.image.Save(d.name); err != nil {
+			logger.Errorf("tape out: %v", err)
+		}
+		logger.Infof("tape out: block %v saved from $%04x-$%04x", d.block, start, end)
+	}
+	if d.Fast {
+		d.skip(cpu, io, end)
+	}
+}
+
+// skip leaves things the way the PROM does at the end of READ or WRITE,
+// with the address one past the end, and jumps to RESTIDX
+func (d *Deck) skip(cpu *CPU.CPU, io *IO.IO, end uint16) {
+	io.Set(hex2, byte(end+1))
+	io.Set(hex2+1, byte((end+1)>>8))
+	io.Set(saveIndex, cpu.X)
+	cpu.SetStatus(CPU.Carry, true)
+	cpu.PC = d.aci.offset + 0x100 + restIdx
+}
+
+func word(io *IO.IO, addr uint16) uint16 {
+	lo, _ := io.Peek(addr)
+	hi, _ := io.Peek(addr + 1)
+	return uint16(hi)<<8 | uint16(lo)
+}
@@ -0,0 +1,149 @@
+package ACI
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+/*
+	Tape images, a compact alternative to recording the audio.  A real ACI
+	tape doesn't know its addresses, the "R" command says where a block
+	goes, but an image remembers where each block was written from.
+
+	"ACI1"            magic
+	then per block:
+	  start  uint16   little endian
+	  end    uint16   inclusive, like "0300.03FFW"
+	  data            end-start+1 bytes
+	  sum    byte     xor of the data
+*/
+
+const tapeMagic = "ACI1"
+
+// Block is one "W" command's worth of tape
+type Block struct {
+	Start uint16
+	Data  []byte
+}
+
+func (b Block) End() uint16 {
+	return b.Start + uint16(len(b.Data)-1)
+}
+
+type Tape struct {
+	Blocks []Block
+}
+
+func IsTape(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(tapeMagic))
+}
+
+func ReadTape(path string) (*Tape, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseTape(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return t, nil
+}
+
+func ParseTape(data []byte) (*Tape, error) {
+	if !IsTape(data) {
+		return nil, fmt.Errorf("not a tape image")
+	}
+	t := &Tape{}
+	for p := len(tapeMagic); p < len(data); {
+		if p+4 > len(data) {
+			return nil, fmt.Errorf("block %v: truncated header", len(t.Blocks)+1)
+		}
+		start := binary.LittleEndian.Uint16(data[p:])
+		end := binary.LittleEndian.Uint16(data[p+2:])
+		n := int(end-start) + 1
+		p += 4
+		if p+n+1 > len(data) {
+			return nil, fmt.Errorf("block %v: $%04x-$%04x is truncated", len(t.Blocks)+1, start, end)
+		}
+		b := Block{
+			Start: start,
+			Data:  append([]byte{}, data[p:p+n]...),
+		}
+		if sum(b.Data) != data[p+n] {
+			return nil, fmt.Errorf("block %v: $%04x-$%04x bad checksum", len(t.Blocks)+1, start, end)
+		}
+		t.Blocks = append(t.Blocks, b)
+		p += n + 1
+	}
+	return t, nil
+}
+
+func (t *Tape) Bytes() []byte {
+	buf := []byte(tapeMagic)
+	for _, b := range t.Blocks {
+		buf = binary.LittleEndian.AppendUint16(buf, b.Start)
+		buf = binary.LittleEndian.AppendUint16(buf, b.End())
+		buf = append(buf, b.Data...)
+		buf = append(buf, sum(b.Data))
+	}
+	return buf
+}
+
+func (t *Tape) Save(path string) error {
+	return os.WriteFile(path, t.Bytes(), 0644)
+}
+
+func sum(data []byte) byte {
+	var s byte
+	for _, v := range data {
+		s ^= v
+	}
+	return s
+}
+
+// phases of the signal the PROM writes, in microseconds
+const (
+	headerPhase = 500 // 1kHz
+	zeroPhase   = 250 // 2kHz
+	onePhase    = 500 // 1kHz
+	headerTime  = 5_000_000
+)
+
+// Audio is the block as the PROM would write it, so an image can be played
+// through the real read loop: a 1kHz header long enough for READ to settle,
+// a short start bit, then the data MSB first, a 2kHz cycle for a 0 and a
+// 1kHz cycle for a 1.
+func (b Block) Audio(rate int) *Wav {
+	w := &Wav{Rate: rate}
+	level := false
+	t := 0 // microseconds
+	phase := func(us int) {
+		t += us
+		for n := int(int64(t) * int64(rate) / 1_000_000); len(w.Levels) < n; {
+			w.Levels = append(w.Levels, level)
+		}
+		level = !level
+	}
+
+	for t < headerTime {
+		phase(headerPhase)
+	}
+	phase(zeroPhase) // start bit
+	phase(zeroPhase)
+	for _, v := range b.Data {
+		for bit := 7; bit >= 0; bit-- {
+			us := zeroPhase
+			if v&(1<<bit) != 0 {
+				us = onePhase
+			}
+			phase(us)
+			phase(us)
+		}
+	}
+	// one more edge to end the last bit on
+	phase(headerPhase)
+	return w
+}
@@ -0,0 +1,200 @@
+package ACI
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SampleRate of the WAV files the ACI writes
+const SampleRate = 44100
+
+const (
+	high = 0xC0 // 8 bit unsigned samples for the two output levels
+	low  = 0x40
+)
+
+// WavWriter writes the cassette output as 8 bit mono PCM.  The header is
+// rewritten every second of audio as well as on Close, so a hard quit still
+// leaves a playable file behind.
+type WavWriter struct {
+	file    *os.File
+	samples uint32 // written so far
+	synced  uint32 // samples the header accounts for
+}
+
+func CreateWav(path string) (*WavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &WavWriter{file: f}
+	if err := w.header(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WavWriter) header() error {
+	h := struct {
+		Riff          [4]byte
+		Size          uint32
+		Wave          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		Riff:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          36 + w.samples,
+		Wave:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1, // PCM
+		Channels:      1,
+		SampleRate:    SampleRate,
+		ByteRate:      SampleRate,
+		BlockAlign:    1,
+		BitsPerSample: 8,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      w.samples,
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(w.file, binary.LittleEndian, h); err != nil {
+		return err
+	}
+	w.synced = w.samples
+	_, err := w.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Write appends n samples at level
+func (w *WavWriter) Write(level bool, n int) error {
+	v := byte(low)
+	if level {
+		v = high
+	}
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = v
+	}
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	w.samples += uint32(n)
+	if w.samples-w.synced >= SampleRate {
+		return w.header()
+	}
+	return nil
+}
+
+func (w *WavWriter) Samples() uint32 {
+	return w.samples
+}
+
+func (w *WavWriter) Close() error {
+	if err := w.header(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Wav is a WAV file read back as levels, one per sample
+type Wav struct {
+	Rate   int
+	Levels []bool
+}
+
+// ReadWav reads 8 or 16 bit PCM, the first channel only, and squares it
+// up into levels around the middle of its range
+func ReadWav(path string) (*Wav, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := ParseWav(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return w, nil
+}
+
+func IsWav(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+func ParseWav(data []byte) (*Wav, error) {
+	if !IsWav(data) {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+
+	var channels, bits int
+	rate := 0
+	var pcm []byte
+	for p := 12; p+8 <= len(data); {
+		id := string(data[p : p+4])
+		size := int(binary.LittleEndian.Uint32(data[p+4 : p+8]))
+		p += 8
+		if p+size > len(data) {
+			// a truncated recording, take what's there
+			size = len(data) - p
+		}
+		chunk := data[p : p+size]
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("bad fmt chunk")
+			}
+			if f := binary.LittleEndian.Uint16(chunk[0:2]); f != 1 {
+				return nil, fmt.Errorf("format %v isn't PCM", f)
+			}
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			rate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+		case "data":
+			pcm = chunk
+		}
+		p += size + size%2
+	}
+	if rate == 0 || pcm == nil {
+		return nil, fmt.Errorf("missing fmt or data chunk")
+	}
+	if bits != 8 && bits != 16 || channels < 1 {
+		return nil, fmt.Errorf("%v bit, %v channel audio isn't supported, use 8 or 16 bit PCM", bits, channels)
+	}
+
+	frame := channels * bits / 8
+	w := &Wav{
+		Rate:   rate,
+		Levels: make([]bool, len(pcm)/frame),
+	}
+	// a little hysteresis, so noise around the middle doesn't flip levels
+	const hysteresis = 0.05
+	level := false
+	for i := range w.Levels {
+		var v float64 // -1..1
+		if bits == 8 {
+			v = (float64(pcm[i*frame]) - 128) / 128
+		} else {
+			v = float64(int16(binary.LittleEndian.Uint16(pcm[i*frame:]))) / 32768
+		}
+		if v > hysteresis {
+			level = true
+		} else if v < -hysteresis {
+			level = false
+		}
+		w.Levels[i] = level
+	}
+	return w, nil
+}
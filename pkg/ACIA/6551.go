@@ -0,0 +1,282 @@
+package ACIA
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/Serial"
+)
+
+var logger = Log.For("acia")
+
+/*
+	The MOS 6551 Asynchronous Communications Interface Adapter.
+
+	0  data      write to transmit, read what was received and clear RDRF
+	1  status    b7 IRQ, b6 /DSR, b5 /DCD, b4 TDRE, b3 RDRF, b2 overrun,
+	             b1 framing error, b0 parity error.  Reading it clears b7
+	             and the IRQ, writing it is a programmed reset
+	2  command   b7-b6 parity mode, b5 parity enable, b4 echo,
+	             b3-b2 transmitter: 00 off, 01 on with its IRQ, 10 on,
+	             11 on sending a break, b1 disables the receiver IRQ,
+	             b0 DTR, 0 turns off the receiver and the IRQs
+	3  control   b7 two stop bits, b6-b5 word length 8-n, b4 receiver
+	             clock source, b3-b0 baud rate, 0 is the 16x external
+	             clock, 115200 with the usual 1.8432MHz crystal
+
+	A character takes its start, data, parity and stop bits at the baud
+	rate to go out or come in, timed by the machine's Clock.  The next
+	byte from the host is only taken once the last one has been read, like
+	RTS/CTS flow control, so pasting into the terminal doesn't overrun.
+
+	The W65C51N never clears TDRE and never raises the transmit IRQ, a
+	byte written while another is going out replaces it.  TxBug turns
+	that on, rom/archive/uart_6551.asm is written around it.
+*/
+
+const (
+	statusIRQ  = 0x80
+	statusTDRE = 0x10
+	statusRDRF = 0x08
+	statusOver = 0x04
+)
+
+var bauds = [16]float64{
+	115200, 50, 75, 109.92, 134.58, 150, 300, 600,
+	1200, 1800, 2400, 3600, 4800, 7200, 9600, 19200,
+}
+
+type MOS6551 struct {
+	TxBug bool                // the W65C51N's transmitter
+	IRQ   func(asserted bool) // the IRQ output changed
+
+	clock   *IO.Clock
+	offset  uint16
+	host    *Serial.Host
+	status  byte
+	command byte
+	control byte
+	irq     bool
+
+	rdr     byte
+	rx      byte      // the byte coming in
+	rxDone  *IO.Event // when it's in
+	tdr     byte
+	tdrFull bool
+	tx      byte // the byte going out
+	txDone  *IO.Event
+}
+
+func New6551(offset uint16, clock *IO.Clock) *MOS6551 {
+	a := &MOS6551{
+		clock:  clock,
+		offset: offset,
+	}
+	a.Reset()
+	return a
+}
+
+// Connect wires the TX and RX pins to the host
+func (a *MOS6551) Connect(host *Serial.Host) {
+	a.host = host
+	a.listen()
+}
+
+// IO.Memory Interface
+func (a *MOS6551) Size() uint16 {
+	return 0x03
+}
+
+func (a *MOS6551) Get(addr uint16) (byte, error) {
+	v, _ := a.Peek(addr)
+	switch (addr - a.offset) & 0x03 {
+	case 0x0:
+		a.status &^= statusRDRF | statusOver
+		a.receive()
+	case 0x1:
+		a.status &^= statusIRQ
+		a.update()
+	}
+	return v, nil
+}
+
+func (a *MOS6551) GetWord(addr uint16) (uint16, error) {
+	lo, _ := a.Get(addr)
+	hi, _ := a.Get(addr + 1)
+	return uint16(hi)<<8 | uint16(lo), nil
+}
+
+func (a *MOS6551) Set(addr uint16, value byte) error {
+	switch (addr - a.offset) & 0x03 {
+	case 0x0:
+		if a.TxBug && a.txDone.Pending() {
+			logger.Debugf("6551: $%02x replaces $%02x going out", value, a.tx)
+			a.clock.Cancel(a.txDone)
+			a.transmit(value)
+			return nil
+		}
+		a.tdr = value
+		a.tdrFull = true
+		a.status &^= statusTDRE
+		a.next()
+	case 0x1:
+		// programmed reset
+		a.command &= 0xE0
+		a.status &^= statusOver
+	case 0x2:
+		logger.Debugf("6551: command $%02x", value)
+		a.command = value
+		if a.txIRQ() && a.status&statusTDRE != 0 {
+			a.interrupt()
+		}
+		a.next()
+		a.receive()
+	case 0x3:
+		logger.Debugf("6551: control $%02x, %v baud", value, bauds[value&0x0F])
+		a.control = value
+	}
+	return nil
+}
+
+func (a *MOS6551) SetWord(addr uint16, value uint16) error {
+	a.Set(addr, byte(value))
+	return a.Set(addr+1, byte(value>>8))
+}
+
+func (a *MOS6551) Load(bytes []byte) (uint16, error) {
+	return 0x00, fmt.Errorf("6551: can't load %v bytes into registers", len(bytes))
+}
+
+// Peek reads a register without clearing RDRF or the IRQ
+func (a *MOS6551) Peek(addr uint16) (byte, error) {
+	switch (addr - a.offset) & 0x03 {
+	case 0x0:
+		return a.rdr, nil
+	case 0x1:
+		if a.TxBug {
+			return a.status | statusTDRE, nil
+		}
+		return a.status, nil
+	case 0x2:
+		return a.command, nil
+	}
+	return a.control, nil
+}
+
+// Reset is the /RES pin
+func (a *MOS6551) Reset() {
+	a.status = statusTDRE
+	a.command = 0x02
+	a.control = 0
+	a.tdrFull = false
+	a.clock.Cancel(a.rxDone)
+	a.clock.Cancel(a.txDone)
+	a.update()
+}
+
+// Asserted is the state of the IRQ output
+func (a *MOS6551) Asserted() bool {
+	return a.status&statusIRQ != 0
+}
+
+// next starts the byte in TDR going out once the last one has gone
+func (a *MOS6551) next() {
+	if a.txDone.Pending() || !a.tdrFull || a.command&0x0C == 0 {
+		return
+	}
+	a.tdrFull = false
+	a.status |= statusTDRE
+	if a.txIRQ() {
+		a.interrupt()
+	}
+	a.transmit(a.tdr)
+}
+
+func (a *MOS6551) transmit(b byte) {
+	a.tx = b
+	a.txDone = a.clock.After(a.charTime(), func() {
+		a.send(a.tx)
+		a.next()
+	})
+}
+
+// receive starts the next byte from the host coming in, if there's one and
+// RDR is free for it
+func (a *MOS6551) receive() {
+	if a.rxDone.Pending() || !a.dtr() || a.status&statusRDRF != 0 || a.host == nil {
+		return
+	}
+	if b, ok := a.host.Receive(); ok {
+		a.rx = b
+		a.rxDone = a.clock.After(a.charTime(), func() {
+			a.received(a.rx)
+		})
+	}
+}
+
+// listen looks for bytes from the host every character time
+func (a *MOS6551) listen() {
+	a.receive()
+	a.clock.After(a.charTime(), a.listen)
+}
+
+func (a *MOS6551) received(b byte) {
+	if a.status&statusRDRF != 0 {
+		a.status |= statusOver
+		return
+	}
+	a.rdr = b
+	a.status |= statusRDRF
+	if a.command&0x1C == 0x10 {
+		// echo
+		a.send(b)
+	}
+	if a.command&0x02 == 0 {
+		a.interrupt()
+	}
+}
+
+func (a *MOS6551) send(b byte) {
+	if a.host != nil {
+		a.host.Send(b)
+	}
+}
+
+func (a *MOS6551) dtr() bool {
+	return a.command&0x01 != 0
+}
+
+func (a *MOS6551) txIRQ() bool {
+	return a.command&0x0C == 0x04 && !a.TxBug
+}
+
+func (a *MOS6551) interrupt() {
+	if a.dtr() {
+		a.status |= statusIRQ
+		a.update()
+	}
+}
+
+// update tells IRQ when the output changes
+func (a *MOS6551) update() {
+	irq := a.Asserted()
+	if irq != a.irq {
+		a.irq = irq
+		if a.IRQ != nil {
+			a.IRQ(irq)
+		}
+	}
+}
+
+// charTime is the cycles a character takes on the line
+func (a *MOS6551) charTime() uint64 {
+	bits := 1 + 8 - int(a.control>>5&0x03) + 1
+	if a.command&0x20 != 0 {
+		bits++
+	}
+	if a.control&0x80 != 0 {
+		bits++
+	}
+	return a.clock.Cycles(float64(bits) / bauds[a.control&0x0F])
+}
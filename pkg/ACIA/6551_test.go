@@ -0,0 +1,135 @@
+package ACIA
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Serial"
+)
+
+// new6551 is a 6551 at $5000 on a 1MHz clock, at 19200 8N1 with DTR on
+func new6551(command byte) (*MOS6551, *IO.Clock) {
+	clock := IO.NewClock(1_000_000)
+	a := New6551(0x5000, clock)
+	a.Set(0x5003, 0x1F)
+	a.Set(0x5002, command)
+	return a, clock
+}
+
+// TestTCP echoes what a TCP client sends, polling the status register the
+// way a monitor's input loop does
+func TestTCP(t *testing.T) {
+	host, err := Serial.Open("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	a, clock := new6551(0x0B) // no IRQs
+	a.Connect(host)
+
+	conn, err := net.Dial("tcp", host.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	want := "6551 round trip"
+	if _, err := conn.Write([]byte(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for echoed := 0; echoed < len(want); {
+		if time.Now().After(deadline) {
+			t.Fatalf("echoed %v of %v bytes", echoed, len(want))
+		}
+		clock.Advance(100)
+		if s, _ := a.Get(0x5001); s&statusRDRF == 0 || s&statusTDRE == 0 {
+			continue
+		}
+		b, _ := a.Get(0x5000)
+		a.Set(0x5000, b)
+		echoed++
+	}
+
+	got := make([]byte, len(want))
+	for n := 0; n < len(got); {
+		clock.Advance(1000)
+		conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		m, err := conn.Read(got[n:])
+		n += m
+		if err != nil && time.Now().After(deadline) {
+			t.Fatalf("read %q back: %v", got[:n], err)
+		}
+	}
+	if string(got) != want {
+		t.Errorf("read %q back, want %q", got, want)
+	}
+}
+
+func TestReceiveIRQ(t *testing.T) {
+	a, clock := new6551(0x09) // receiver IRQ on
+	var irqs []bool
+	a.IRQ = func(asserted bool) {
+		irqs = append(irqs, asserted)
+	}
+	host := &Serial.Host{}
+	host.Type([]byte("AB"))
+	a.Connect(host)
+
+	clock.Advance(a.charTime() * 2)
+	if !a.Asserted() {
+		t.Fatal("no IRQ with a byte in")
+	}
+	if s, _ := a.Get(0x5001); s != statusIRQ|statusTDRE|statusRDRF {
+		t.Errorf("status $%02x, want IRQ, TDRE and RDRF", s)
+	}
+	if a.Asserted() {
+		t.Error("IRQ still asserted after reading the status")
+	}
+	if b, _ := a.Get(0x5000); b != 'A' {
+		t.Errorf("read %q, want 'A'", b)
+	}
+	clock.Advance(a.charTime() * 2)
+	if b, _ := a.Get(0x5000); b != 'B' {
+		t.Errorf("read %q, want 'B'", b)
+	}
+	if len(irqs) != 3 || !irqs[0] || irqs[1] || !irqs[2] {
+		t.Errorf("IRQ went %v, want [true false true]", irqs)
+	}
+}
+
+// TestFlowControl leaves a byte unread, the next one has to wait for it
+// rather than overrun it
+func TestFlowControl(t *testing.T) {
+	a, clock := new6551(0x0B)
+	host := &Serial.Host{}
+	a.Connect(host)
+	clock.Advance(a.charTime())
+	host.Type([]byte("A"))
+	clock.Advance(a.charTime() * 3)
+	host.Type([]byte("B"))
+	clock.Advance(a.charTime() * 3)
+	if s, _ := a.Get(0x5001); s&statusOver != 0 {
+		t.Errorf("status $%02x, an overrun with flow control", s)
+	}
+	if !host.Pending() {
+		t.Error("took 'B' with RDR full")
+	}
+}
+
+func TestTxBug(t *testing.T) {
+	a, clock := new6551(0x0B)
+	a.TxBug = true
+	a.Set(0x5000, 'A')
+	clock.Advance(a.charTime() / 2)
+	a.Set(0x5000, 'B') // replaces 'A' halfway out
+	if s, _ := a.Get(0x5001); s&statusTDRE == 0 {
+		t.Errorf("status $%02x, the W65C51N always has TDRE set", s)
+	}
+	if a.tx != 'B' {
+		t.Errorf("sending %q, want 'B'", a.tx)
+	}
+}
@@ -0,0 +1,229 @@
+package ACIA
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Serial"
+)
+
+/*
+	The Motorola MC6850 Asynchronous Communications Interface Adapter.
+
+	0  control   write: b7 receive IRQ enable, b6-b5 transmitter: 00 RTS
+	             low, 01 the same with the transmit IRQ, 10 RTS high,
+	             11 RTS low sending a break, b4-b2 word select: 000 7E2,
+	             001 7O2, 010 7E1, 011 7O1, 100 8N2, 101 8N1, 110 8E1,
+	             111 8O1, b1-b0 counter divide 1, 16, 64 or 11 master reset
+	   status    read: b7 IRQ, b6 parity error, b5 overrun, b4 framing
+	             error, b3 /CTS, b2 /DCD, b1 TDRE, b0 RDRF
+	1  data      write to transmit, read what was received
+
+	There's no baud rate generator, the baud rate is the BaudClock on the
+	TX and RX clock pins over the counter divide.  IRQ follows the flags: RDRF
+	or overrun with the receive IRQ on, or TDRE with the transmit IRQ on,
+	reading or writing the data clears them.  Until the first master reset
+	the chip does nothing.
+*/
+
+const (
+	mcRDRF = 0x01
+	mcTDRE = 0x02
+	mcOver = 0x20
+	mcIRQ  = 0x80
+)
+
+// data bits and frame length for each word select
+var mcWords = [8]struct{ data, bits int }{
+	{7, 11}, {7, 11}, {7, 10}, {7, 10}, {8, 11}, {8, 10}, {8, 11}, {8, 11},
+}
+
+type MC6850 struct {
+	BaudClock float64             // on the TX and RX clock pins, 1.8432MHz unless set
+	IRQ       func(asserted bool) // the IRQ output changed
+
+	clock   *IO.Clock
+	offset  uint16
+	host    *Serial.Host
+	control byte
+	status  byte
+	reset   bool // held in master reset
+	irq     bool
+
+	rdr    byte
+	rx     byte
+	rxDone *IO.Event
+	tdr    byte
+	tx     byte
+	txDone *IO.Event
+	poll   *IO.Event // the next look for a byte from the host
+}
+
+func New6850(offset uint16, clock *IO.Clock) *MC6850 {
+	return &MC6850{
+		BaudClock: 1_843_200,
+		clock:     clock,
+		offset:    offset,
+		reset:     true,
+	}
+}
+
+// Connect wires the TX and RX pins to the host
+func (a *MC6850) Connect(host *Serial.Host) {
+	a.host = host
+	a.listen()
+}
+
+// IO.Memory Interface
+func (a *MC6850) Size() uint16 {
+	return 0x01
+}
+
+func (a *MC6850) Get(addr uint16) (byte, error) {
+	v, _ := a.Peek(addr)
+	if (addr-a.offset)&0x01 == 1 {
+		a.status &^= mcRDRF | mcOver
+		a.receive()
+		a.update()
+	}
+	return v, nil
+}
+
+func (a *MC6850) GetWord(addr uint16) (uint16, error) {
+	lo, _ := a.Get(addr)
+	hi, _ := a.Get(addr + 1)
+	return uint16(hi)<<8 | uint16(lo), nil
+}
+
+func (a *MC6850) Set(addr uint16, value byte) error {
+	defer a.update()
+	if (addr-a.offset)&0x01 == 0 {
+		a.control = value
+		if value&0x03 == 0x03 {
+			logger.Debugf("6850: master reset")
+			a.reset = true
+			a.status = 0
+			a.clock.Cancel(a.rxDone)
+			a.clock.Cancel(a.txDone)
+			a.clock.Cancel(a.poll)
+			return nil
+		}
+		if a.reset {
+			a.reset = false
+			a.status |= mcTDRE
+			if a.host != nil {
+				a.listen()
+			}
+		}
+		logger.Debugf("6850: control $%02x, %v baud", value, a.baud())
+		return nil
+	}
+	if a.reset {
+		return nil
+	}
+	a.tdr = value
+	a.status &^= mcTDRE
+	a.next()
+	return nil
+}
+
+func (a *MC6850) SetWord(addr uint16, value uint16) error {
+	a.Set(addr, byte(value))
+	return a.Set(addr+1, byte(value>>8))
+}
+
+func (a *MC6850) Load(bytes []byte) (uint16, error) {
+	return 0x00, fmt.Errorf("6850: can't load %v bytes into registers", len(bytes))
+}
+
+// Peek reads a register without clearing RDRF
+func (a *MC6850) Peek(addr uint16) (byte, error) {
+	if (addr-a.offset)&0x01 == 1 {
+		return a.rdr, nil
+	}
+	s := a.status
+	if a.Asserted() {
+		s |= mcIRQ
+	}
+	return s, nil
+}
+
+// Asserted is the state of the IRQ output
+func (a *MC6850) Asserted() bool {
+	if a.reset {
+		return false
+	}
+	rx := a.control&0x80 != 0 && a.status&(mcRDRF|mcOver) != 0
+	tx := a.control&0x60 == 0x20 && a.status&mcTDRE != 0
+	return rx || tx
+}
+
+// next starts the byte in TDR going out once the last one has gone
+func (a *MC6850) next() {
+	if a.reset || a.txDone.Pending() || a.status&mcTDRE != 0 {
+		return
+	}
+	a.tx = a.tdr
+	a.status |= mcTDRE
+	a.txDone = a.clock.After(a.charTime(), func() {
+		if a.host != nil {
+			a.host.Send(a.tx & a.mask())
+		}
+		a.next()
+		a.update()
+	})
+}
+
+// receive starts the next byte from the host coming in, if there's one and
+// RDR is free for it
+func (a *MC6850) receive() {
+	if a.reset || a.rxDone.Pending() || a.status&mcRDRF != 0 || a.host == nil {
+		return
+	}
+	if b, ok := a.host.Receive(); ok {
+		a.rx = b
+		a.rxDone = a.clock.After(a.charTime(), func() {
+			a.rdr = a.rx & a.mask()
+			a.status |= mcRDRF
+			a.update()
+		})
+	}
+}
+
+// update tells IRQ when the output changes
+func (a *MC6850) update() {
+	irq := a.Asserted()
+	if irq != a.irq {
+		a.irq = irq
+		if a.IRQ != nil {
+			a.IRQ(irq)
+		}
+	}
+}
+
+// listen looks for bytes from the host every character time, except in
+// master reset when there's no baud rate to go by
+func (a *MC6850) listen() {
+	if a.reset {
+		return
+	}
+	a.receive()
+	a.poll = a.clock.After(a.charTime(), a.listen)
+}
+
+func (a *MC6850) baud() float64 {
+	return a.BaudClock / [4]float64{1, 16, 64, 1}[a.control&0x03]
+}
+
+func (a *MC6850) mask() byte {
+	if mcWords[a.control>>2&0x07].data == 7 {
+		return 0x7F
+	}
+	return 0xFF
+}
+
+// charTime is the cycles a character takes on the line
+func (a *MC6850) charTime() uint64 {
+	bits := mcWords[a.control>>2&0x07].bits
+	return a.clock.Cycles(float64(bits) / a.baud())
+}
@@ -0,0 +1,123 @@
+package ACIA
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Serial"
+)
+
+func new6850() (*MC6850, *IO.Clock) {
+	clock := IO.NewClock(1_000_000)
+	return New6850(0x8200, clock), clock
+}
+
+func TestMasterReset(t *testing.T) {
+	a, clock := new6850()
+	var irqs []bool
+	a.IRQ = func(asserted bool) {
+		irqs = append(irqs, asserted)
+	}
+	host := &Serial.Host{}
+	host.Type([]byte("A"))
+	a.Connect(host)
+
+	// nothing happens until the chip's been set up
+	clock.Advance(100_000)
+	if s, _ := a.Get(0x8200); s != 0 || !host.Pending() {
+		t.Fatalf("status $%02x before a master reset", s)
+	}
+
+	a.Set(0x8200, 0x03)
+	a.Set(0x8200, 0x95) // receive IRQ, 8N1, /16
+	clock.Advance(a.charTime() * 2)
+	if s, _ := a.Get(0x8200); s != mcIRQ|mcTDRE|mcRDRF {
+		t.Fatalf("status $%02x, want IRQ, TDRE and RDRF", s)
+	}
+	if b, _ := a.Get(0x8201); b != 'A' {
+		t.Errorf("read %q, want 'A'", b)
+	}
+	if a.Asserted() {
+		t.Error("IRQ still asserted after reading the data")
+	}
+
+	host.Type([]byte("B"))
+	clock.Advance(a.charTime() * 2)
+	a.Set(0x8200, 0x03)
+	if s, _ := a.Get(0x8200); s != 0 {
+		t.Errorf("status $%02x in master reset", s)
+	}
+	if len(irqs) != 4 || !irqs[0] || irqs[1] || !irqs[2] || irqs[3] {
+		t.Errorf("IRQ went %v, want [true false true false]", irqs)
+	}
+
+	// and it stops listening
+	a.Get(0x8201)
+	clock.Advance(100_000)
+	host.Type([]byte("C"))
+	clock.Advance(100_000)
+	if !host.Pending() {
+		t.Error("took a byte in master reset")
+	}
+}
+
+func TestTransmitIRQ(t *testing.T) {
+	a, clock := new6850()
+	a.Set(0x8200, 0x03)
+	a.Set(0x8200, 0x35) // transmit IRQ, 8N1, /16
+	if !a.Asserted() {
+		t.Fatal("no IRQ with TDRE set")
+	}
+	a.Set(0x8201, 'A')
+	a.Set(0x8201, 'B') // TDR while 'A' goes out
+	if a.Asserted() {
+		t.Fatal("IRQ with a byte waiting in TDR")
+	}
+	clock.Advance(a.charTime())
+	if !a.Asserted() {
+		t.Fatal("no IRQ once 'B' moved out of TDR")
+	}
+}
+
+// TestSevenBits sends and receives 7E1 over TCP, bit 7 doesn't make it
+func TestSevenBits(t *testing.T) {
+	host, err := Serial.Open("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	a, clock := new6850()
+	a.Connect(host)
+	a.Set(0x8200, 0x03)
+	a.Set(0x8200, 0x09) // 7E1, /16
+
+	conn, err := net.Dial("tcp", host.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte{0xC1})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("nothing received")
+		}
+		clock.Advance(100)
+		if s, _ := a.Get(0x8200); s&mcRDRF != 0 {
+			break
+		}
+	}
+	if b, _ := a.Get(0x8201); b != 0x41 {
+		t.Errorf("received $%02x, want $41", b)
+	}
+
+	a.Set(0x8201, 0xC2)
+	clock.Advance(a.charTime())
+	b := make([]byte, 1)
+	conn.SetReadDeadline(deadline)
+	if _, err := conn.Read(b); err != nil || b[0] != 0x42 {
+		t.Errorf("host read $%02x, %v, want $42", b[0], err)
+	}
+}
@@ -0,0 +1,157 @@
+package Bank
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/Memory"
+)
+
+var logger = Log.For("bank")
+
+/*
+	Bank-switched memory, N banks of RAM or ROM behind one address window.
+
+	Only the active bank is visible on the bus, a Select latch somewhere
+	else in the memory map picks which one that is:
+
+	b := Bank.New("HIMEM", 0x8000)
+	b.Add(Memory.New(0x3FFF, 0x8000, false)) // bank 0, RAM
+	b.Add(Memory.New(0x3FFF, 0x8000, true))  // bank 1, ROM
+	latch := Bank.NewSelect(0xC000, b)        // write 1 to $C000 for ROM
+*/
+
+type Bank struct {
+	Name   string
+	banks  []*Memory.Memory
+	active int
+	offset uint16
+}
+
+func New(name string, offset uint16) *Bank {
+	return &Bank{
+		Name:   name,
+		banks:  make([]*Memory.Memory, 0),
+		offset: offset,
+	}
+}
+
+// Add appends a bank, every bank should cover the same window
+func (b *Bank) Add(m *Memory.Memory) int {
+	b.banks = append(b.banks, m)
+	return len(b.banks) - 1
+}
+
+func (b *Bank) Banks() int {
+	return len(b.banks)
+}
+
+func (b *Bank) Active() int {
+	return b.active
+}
+
+// Select makes bank n the one visible on the bus, banks past the end wrap
+// the way a latch wired to too few bank lines would
+func (b *Bank) Select(n int) {
+	n %= len(b.banks)
+	if n != b.active {
+		logger.Debugf("%v: bank %v -> %v", b.Name, b.active, n)
+	}
+	b.active = n
+}
+
+// Bank returns bank n, or nil if there isn't one
+func (b *Bank) Bank(n int) *Memory.Memory {
+	if n < 0 || n >= len(b.banks) {
+		return nil
+	}
+	return b.banks[n]
+}
+
+// PeekBank reads addr from bank n without switching to it
+func (b *Bank) PeekBank(n int, addr uint16) (byte, error) {
+	m := b.Bank(n)
+	if m == nil {
+		return 0x00, fmt.Errorf("%v: no bank %v, there are %v", b.Name, n, len(b.banks))
+	}
+	return m.Peek(addr)
+}
+
+func (b *Bank) Peek(addr uint16) (byte, error) {
+	return b.banks[b.active].Peek(addr)
+}
+
+func (b *Bank) Poke(addr uint16, value byte) error {
+	return b.banks[b.active].Poke(addr, value)
+}
+
+// IO.Memory Interface
+func (b *Bank) Size() uint16 {
+	return b.banks[0].Size()
+}
+
+func (b *Bank) Get(addr uint16) (byte, error) {
+	return b.banks[b.active].Get(addr)
+}
+
+func (b *Bank) GetWord(addr uint16) (uint16, error) {
+	return b.banks[b.active].GetWord(addr)
+}
+
+func (b *Bank) Set(addr uint16, value byte) error {
+	return b.banks[b.active].Set(addr, value)
+}
+
+func (b *Bank) SetWord(addr uint16, value uint16) error {
+	return b.banks[b.active].SetWord(addr, value)
+}
+
+func (b *Bank) Load(bytes []byte) (uint16, error) {
+	return b.banks[b.active].Load(bytes)
+}
+
+// Select is a write only bank latch, the value written (after Mask) picks
+// the active bank of every Bank it drives.  Reads return the latch.
+type Select struct {
+	offset uint16
+	banks  []*Bank
+	Mask   byte
+	value  byte
+}
+
+func NewSelect(offset uint16, banks ...*Bank) *Select {
+	return &Select{
+		offset: offset,
+		banks:  banks,
+		Mask:   0xFF,
+	}
+}
+
+// IO.Memory Interface
+func (s *Select) Size() uint16 {
+	return 0
+}
+
+func (s *Select) Get(addr uint16) (byte, error) {
+	return s.value, nil
+}
+
+func (s *Select) GetWord(addr uint16) (uint16, error) {
+	return uint16(s.value)<<8 | uint16(s.value), nil
+}
+
+func (s *Select) Set(addr uint16, value byte) error {
+	s.value = value & s.Mask
+	for _, b := range s.banks {
+		b.Select(int(s.value))
+	}
+	return nil
+}
+
+func (s *Select) SetWord(addr uint16, value uint16) error {
+	return s.Set(addr+1, byte(value>>8))
+}
+
+func (s *Select) Load(bytes []byte) (uint16, error) {
+	return 0x00, fmt.Errorf("not implemented: %v", len(bytes))
+}
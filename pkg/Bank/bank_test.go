@@ -0,0 +1,53 @@
+package Bank
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/Memory"
+)
+
+func TestSelect(t *testing.T) {
+	b := New("HIMEM", 0x8000)
+	b.Add(Memory.New(0x3FFF, 0x8000, false))
+	rom := Memory.New(0x3FFF, 0x8000, true)
+	rom.Bytes[0x0000] = 0x4C
+	b.Add(rom)
+	latch := NewSelect(0xC000, b)
+	latch.Mask = 0x01
+
+	b.Set(0x8000, 0x11)
+	latch.Set(0xC000, 0x03) // bit 1 isn't wired
+	if b.Active() != 1 {
+		t.Fatalf("bank %v active, want 1", b.Active())
+	}
+	if v, _ := latch.Get(0xC000); v != 0x01 {
+		t.Errorf("latch reads $%02x, want $01", v)
+	}
+	if v, _ := b.Get(0x8000); v != 0x4C {
+		t.Errorf("ROM bank read $%02x, want $4C", v)
+	}
+	if v, _ := b.PeekBank(0, 0x8000); v != 0x11 {
+		t.Errorf("RAM bank peeked $%02x, want $11", v)
+	}
+	if _, err := b.PeekBank(2, 0x8000); err == nil {
+		t.Error("peeked a bank that isn't there")
+	}
+
+	latch.Set(0xC000, 0x00)
+	if v, _ := b.Get(0x8000); v != 0x11 {
+		t.Errorf("RAM bank read $%02x, want $11", v)
+	}
+}
+
+// TestWrap selects past the last bank, like a latch with more lines than
+// there are banks
+func TestWrap(t *testing.T) {
+	b := New("HIMEM", 0x8000)
+	for i := 0; i < 3; i++ {
+		b.Add(Memory.New(0x00FF, 0x8000, false))
+	}
+	b.Select(4)
+	if b.Active() != 1 {
+		t.Fatalf("bank 4 of 3 selected %v, want 1", b.Active())
+	}
+}
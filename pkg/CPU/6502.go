@@ -2,13 +2,8 @@ package CPU
 
 import (
 	"fmt"
-	"image"
-	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/zoul0813/go6502/pkg/IO"
-	"golang.org/x/image/font"
 )
 
 type StatusFlag uint8
@@ -59,6 +54,8 @@ type CPU struct {
 	SingleStep bool
 	Address    uint16
 	DebugMode  bool
+	Cycles     uint64         // cycles executed since power on
+	Interrupts *IO.Interrupts // IRQ serviced while I is clear, NMI on the edge
 	halted     bool
 }
 
@@ -119,12 +116,57 @@ func New(
 	}
 }
 
+// Reset does what pulling the RESET line does, it loads the PC from the
+// reset vector at $FFFC, disables interrupts and drops three bytes off the
+// stack pointer
+func (o *CPU) Reset(io IO.Memory) error {
+	pc, err := io.GetWord(0xFFFC)
+	o.PC = pc
+	o.SP -= 3
+	o.SetStatus(Interrupt, true)
+	o.halted = false
+	return err
+}
+
+// interrupt pushes the PC and the status, with B clear, disables
+// interrupts and jumps through vector, like BRK without the B
+func (o *CPU) interrupt(io IO.Memory, vector uint16) error {
+	io.Set(STACK_HEAD+uint16(o.SP), uint8(o.PC>>8))
+	o.SP--
+	io.Set(STACK_HEAD+uint16(o.SP), uint8(o.PC))
+	o.SP--
+	io.Set(STACK_HEAD+uint16(o.SP), o.Status&^B|Reserved)
+	o.SP--
+	o.SetStatus(Interrupt, true)
+	pc, err := io.GetWord(vector)
+	o.PC = pc
+	o.Cycles += 7
+	o.halted = false
+	return err
+}
+
 func (o *CPU) Step(io IO.Memory) (bool, error) {
+	// interrupts are taken between instructions
+	if o.Interrupts != nil {
+		switch {
+		case o.Interrupts.NMI():
+			return false, o.interrupt(io, 0xFFFA)
+		case o.Interrupts.IRQ() && o.Status&Interrupt == 0:
+			return false, o.interrupt(io, 0xFFFE)
+		}
+	}
+
 	halted := false
-	b, _ := io.Get(o.PC)
+	var b byte
+	if f, ok := io.(IO.Fetcher); ok {
+		b, _ = f.Fetch(o.PC)
+	} else {
+		b, _ = io.Get(o.PC)
+	}
 	var instr OpCode = OpCode(b)
 	o.Log("Instruction: %02x @ %04x\n", instr, o.PC)
 	o.PC++ // increment the stack pointer
+	o.Cycles += uint64(cycles[instr])
 	switch instr {
 	// Jump/Branch Instructions
 	case JMP_A:
@@ -944,15 +986,13 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		o.Log("%02x (ZP) %02x", addr, b)
 		v := b + 1
 		err := io.Set(addr, v)
-		fmt.Printf(" %02x %04x", v, addr)
-		t, _ := io.Get(addr)
-		fmt.Printf(" %02x", t)
+		o.Log(" %02x %04x", v, addr)
 		if err != nil {
-			fmt.Printf(" %s", err)
+			o.Log(" %s", err)
 		}
 
-		o.SetStatus(Zero, b == 0)
-		o.SetStatus(Negative, IsNegative(b))
+		o.SetStatus(Zero, v == 0)
+		o.SetStatus(Negative, IsNegative(v))
 	case INC_ZPX:
 		// increment zero page, x
 		o.Log("I: INC ")
@@ -1583,7 +1623,7 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		b, _ := io.Get(addr)
 		o.Log("%02x (ZP)", addr)
 
-		carry := BitTest(Bit1, b)
+		carry := BitTest(Bit0, b)
 		b = b >> 1
 		io.Set(addr, b)
 
@@ -1597,7 +1637,7 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		b, _ := io.Get(addr)
 		o.Log("%02x (ZP, X)", addr)
 
-		carry := BitTest(Bit1, b)
+		carry := BitTest(Bit0, b)
 		b = b >> 1
 		io.Set(addr, b)
 
@@ -1611,7 +1651,7 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		b, _ := io.Get(addr)
 		o.Log("%04x (ABS)", addr)
 
-		carry := BitTest(Bit1, b)
+		carry := BitTest(Bit0, b)
 		b = b >> 1
 		io.Set(addr, b)
 
@@ -1625,7 +1665,7 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		b, _ := io.Get(addr)
 		o.Log("%04x (ABS, X)", addr)
 
-		carry := BitTest(Bit1, b)
+		carry := BitTest(Bit0, b)
 		b = b >> 1
 		io.Set(addr, b)
 
@@ -1642,7 +1682,8 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		a := o.A
 		v := a & b
 
-		o.SetStatus(Overflow, IsOverflow(b, v))
+		// N and V are bits 7 and 6 of memory, e.g. a PIA's IRQ flags
+		o.SetStatus(Overflow, BitTest(Bit6, b))
 		o.SetStatus(Zero, v == 0)
 		o.SetStatus(Negative, IsNegative(b))
 	case BIT_A:
@@ -1655,7 +1696,8 @@ func (o *CPU) Step(io IO.Memory) (bool, error) {
 		a := o.A
 		v := a & b
 
-		o.SetStatus(Overflow, IsOverflow(b, v))
+		// N and V are bits 7 and 6 of memory, e.g. a PIA's IRQ flags
+		o.SetStatus(Overflow, BitTest(Bit6, b))
 		o.SetStatus(Zero, v == 0)
 		o.SetStatus(Negative, IsNegative(b))
 
@@ -1763,7 +1805,9 @@ func (o *CPU) DebugBits() {
 	fmt.Print("          NV-BDIZC\n\n")
 }
 
-func (o *CPU) DebugRegister(screen *ebiten.Image, font font.Face, bound image.Rectangle, screenHeight int, screenWidth int) {
+// RegisterString renders the registers the way Debug prints them, for front
+// ends that draw the register overlay themselves
+func (o *CPU) RegisterString() string {
 	s := ""
 	s += "PC    SP  A    X    Y    Status     \n"
 	s += "-------------------------NV-BDIZC- ($SS)\n"
@@ -1777,19 +1821,7 @@ func (o *CPU) DebugRegister(screen *ebiten.Image, font font.Face, bound image.Re
 		o.Status,
 		o.Status,
 	)
-
-	dScale := 2.0
-	x := float64(bound.Dx())
-	y := float64(bound.Dy())
-	x = float64(screenWidth) - ((x * dScale) * 50) // width of string
-	y = float64(screenHeight) - ((y * dScale) * 4) // number of lines
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(dScale, dScale)
-	op.GeoM.Translate(float64(x), float64(y))
-	op.Filter = ebiten.FilterNearest
-	clr := color.RGBA{0, 110, 62, 20}
-	op.ColorScale.ScaleWithColor(clr)
-	text.DrawWithOptions(screen, s, font, op)
+	return s
 }
 
 // func (o *CPU) Write(io *Memory.Memory, b uint8) error {
@@ -1914,6 +1946,7 @@ func (o *CPU) SBC(operand uint8) uint8 {
 func (o *CPU) Branch(rel uint8, cond bool) {
 	o.PC++ // always increment the PC by 1 to account for the offset?
 	if cond {
+		o.Cycles++ // taken branches cost an extra cycle
 		j := ^rel + 1
 		o.Log(" Taken, %02x %08b | %02x %08b %v", rel, rel, j, j, j)
 		if BitTest(Bit7, rel) {
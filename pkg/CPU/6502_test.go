@@ -0,0 +1,148 @@
+package CPU
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Memory"
+)
+
+// BenchmarkStep runs a loop of loads, stores and increments out of RAM,
+// instructions per second is 1e9 over ns/op
+func BenchmarkStep(b *testing.B) {
+	ram := Memory.New(0xFFFF, 0x0000, false)
+	copy(ram.Bytes[0x0300:], []byte{
+		0xA5, 0x10, // LDA $10
+		0x85, 0x11, // STA $11
+		0xE8,             // INX
+		0x4C, 0x00, 0x03, // JMP $0300
+	})
+	io := IO.New([]*IO.Device{IO.NewDevice("RAM", ram, 0x0000)})
+	cpu := New(0x0300, 0xFF, 0, 0, 0, 0x30, false, false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cpu.Step(io)
+	}
+}
+
+// run steps through program at $0300 with zero page set up from zp
+func run(program []byte, zp map[byte]byte, a byte, steps int) (*CPU, *Memory.Memory) {
+	ram := Memory.New(0xFFFF, 0x0000, false)
+	copy(ram.Bytes[0x0300:], program)
+	for addr, v := range zp {
+		ram.Bytes[addr] = v
+	}
+	io := IO.New([]*IO.Device{IO.NewDevice("RAM", ram, 0x0000)})
+	cpu := New(0x0300, 0xFF, a, 0, 0, 0x30, false, false)
+	for i := 0; i < steps; i++ {
+		cpu.Step(io)
+	}
+	return cpu, ram
+}
+
+// TestInterrupts takes an NMI on its edge and an IRQ once I is clear
+func TestInterrupts(t *testing.T) {
+	ram := Memory.New(0xFFFF, 0x0000, false)
+	copy(ram.Bytes[0x0300:], []byte{
+		0xEA, // NOP
+		0x58, // CLI
+		0xEA, // NOP
+	})
+	ram.Bytes[0xFFFA], ram.Bytes[0xFFFB] = 0x00, 0x10 // NMI $1000
+	ram.Bytes[0xFFFE], ram.Bytes[0xFFFF] = 0x00, 0x20 // IRQ $2000
+	io := IO.New([]*IO.Device{IO.NewDevice("RAM", ram, 0x0000)})
+	lines := IO.NewInterrupts()
+	irq := lines.Add("VIA", false)
+	nmi := lines.Add("NMI", true)
+	cpu := New(0x0300, 0xFF, 0, 0, 0, 0x34, false, false) // I set
+	cpu.Interrupts = lines
+
+	lines.Set(irq, true)
+	cpu.Step(io)
+	if cpu.PC != 0x0301 {
+		t.Fatalf("PC $%04x, the IRQ was taken with I set", cpu.PC)
+	}
+	cpu.Step(io) // CLI
+	cpu.Step(io)
+	if cpu.PC != 0x2000 {
+		t.Fatalf("PC $%04x, want the IRQ handler", cpu.PC)
+	}
+	if ret := uint16(ram.Bytes[0x01FF])<<8 | uint16(ram.Bytes[0x01FE]); ret != 0x0302 {
+		t.Errorf("pushed $%04x, want $0302", ret)
+	}
+	if p := ram.Bytes[0x01FD]; p&B != 0 || p&Interrupt != 0 {
+		t.Errorf("pushed P $%02x, want B and I clear", p)
+	}
+
+	lines.Set(nmi, true)
+	cpu.Step(io)
+	if cpu.PC != 0x1000 {
+		t.Fatalf("PC $%04x, want the NMI handler", cpu.PC)
+	}
+	cpu.Step(io)
+	if cpu.PC == 0x1000 {
+		t.Error("NMI taken again without an edge")
+	}
+}
+
+func TestINCFlags(t *testing.T) {
+	for _, c := range []struct {
+		value byte
+		n, z  bool
+	}{
+		{0x7F, true, false},
+		{0xFF, false, true},
+		{0x01, false, false},
+	} {
+		// A is left at $01 so the flags can only come from memory
+		cpu, ram := run([]byte{0xE6, 0x10}, map[byte]byte{0x10: c.value}, 0x01, 1) // INC $10
+		if ram.Bytes[0x10] != c.value+1 {
+			t.Errorf("INC $%02x stored $%02x", c.value, ram.Bytes[0x10])
+		}
+		if n, z := cpu.Status&Negative != 0, cpu.Status&Zero != 0; n != c.n || z != c.z {
+			t.Errorf("INC $%02x set N %v Z %v, want N %v Z %v", c.value, n, z, c.n, c.z)
+		}
+	}
+}
+
+func TestBIT(t *testing.T) {
+	for _, c := range []struct {
+		a, value byte
+		n, v, z  bool
+	}{
+		{0xFF, 0x40, false, true, false},
+		{0xFF, 0x80, true, false, false},
+		{0x01, 0xC0, true, true, true}, // N and V from memory even when A & M is 0
+		{0x00, 0x3F, false, false, true},
+	} {
+		cpu, _ := run([]byte{0x24, 0x10}, map[byte]byte{0x10: c.value}, c.a, 1) // BIT $10
+		n, v, z := cpu.Status&Negative != 0, cpu.Status&Overflow != 0, cpu.Status&Zero != 0
+		if n != c.n || v != c.v || z != c.z {
+			t.Errorf("BIT $%02x with A $%02x set N %v V %v Z %v, want N %v V %v Z %v", c.value, c.a, n, v, z, c.n, c.v, c.z)
+		}
+		if cpu.A != c.a {
+			t.Errorf("BIT changed A to $%02x", cpu.A)
+		}
+	}
+}
+
+func TestLSRMemory(t *testing.T) {
+	for _, c := range []struct {
+		value byte
+		carry bool
+	}{
+		{0x01, true},
+		{0x02, false},
+		{0x81, true},
+	} {
+		// A's bit 0 is the opposite of memory's, so a carry from A shows
+		cpu, ram := run([]byte{0x46, 0x10}, map[byte]byte{0x10: c.value}, ^c.value, 1) // LSR $10
+		if ram.Bytes[0x10] != c.value>>1 {
+			t.Errorf("LSR $%02x stored $%02x", c.value, ram.Bytes[0x10])
+		}
+		if carry := cpu.Status&Carry != 0; carry != c.carry {
+			t.Errorf("LSR $%02x set C %v, want %v", c.value, carry, c.carry)
+		}
+	}
+}
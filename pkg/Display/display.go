@@ -1,35 +1,73 @@
 package Display
 
 import (
-	"fmt"
+	"sync"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/PIA"
 )
 
+var logger = Log.For("display")
+
+// Listener is called with every character written to the display, after
+// bit 7 has been stripped.  Listeners run with the display locked, they
+// must not call back into it.
+type Listener func(c byte)
+
 type Display struct {
-	buffer []byte
-	size   int
-	offset uint16
-	cols   int
-	rows   int
-	mode   uint8
-	col    int
-	row    int
-	blink  int
+	Rate float64 // characters a second, 0 takes them straight away
+
+	clock     *IO.Clock
+	buffer    []byte
+	size      int
+	cols      int
+	rows      int
+	data      byte // on PB0-PB7
+	col       int
+	row       int
+	blink     int
+	listeners []Listener
+	mutex     sync.Mutex
 }
 
-func New(offset uint16, cols int, rows int) *Display {
+func New(cols int, rows int, clock *IO.Clock) *Display {
 	size := cols * rows
-	return &Display{
-		offset: offset,
+	d := &Display{
+		clock:  clock,
 		cols:   cols,
 		rows:   rows,
 		size:   size,
 		buffer: make([]byte, size),
-		mode:   0x00,
 		blink:  0,
 	}
+	for i := range d.buffer {
+		d.buffer[i] = 0x20 // Space
+	}
+	return d
+}
+
+func (d *Display) Listen(l Listener) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.listeners = append(d.listeners, l)
+}
+
+// Dimensions returns the size of the screen in columns and rows
+func (d *Display) Dimensions() (int, int) {
+	return d.cols, d.rows
+}
+
+// Cursor returns the column and row the next character will be written to
+func (d *Display) Cursor() (int, int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.col, d.row
 }
 
 func (d *Display) All(blink bool) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 	t := ""
 
 	for r := 0; r < d.rows; r++ {
@@ -50,93 +88,70 @@ func (d *Display) All(blink bool) string {
 	return t
 }
 
-// IO.Memory Interface
-
-func (d *Display) Size() uint16 {
-	return 1
-}
-
-func (d *Display) Set(addr uint16, value byte) error {
-	if addr == d.offset+1 {
-		fmt.Printf("display setting control registers: $%04x $%02x\n", addr, value)
-		d.mode = value
-		d.col = 0
-		d.row = 0
-		for i, _ := range d.buffer {
-			d.buffer[i] = 0x20 // Space
+// Connect wires the display to a PIA port the way the Apple-1 does.  PB0-PB6
+// are the character, CB2 is DA, the strobe that says there is one, and PB7
+// reads DA back so the CPU can wait for the display.  The display takes
+// the character, after 1/Rate seconds if there's a Rate, and answers with
+// RDA on CB1, which ends the handshake.
+func (d *Display) Connect(port *PIA.Port) {
+	port.Output = func(value byte) {
+		d.data = value
+	}
+	port.Input = func() byte {
+		if !port.C2Level() {
+			return 0x80
 		}
-		return nil
+		return 0x00
 	}
-
-	if d.mode == 0x00 {
-		fmt.Printf("display in configuration mode, ignoring: $%04x $%02x\n", addr, value)
-		return nil
+	port.C2 = func(level bool) {
+		if level {
+			return
+		}
+		value := d.data
+		done := func() {
+			d.write(value)
+			port.SetC1(true)
+			port.SetC1(false)
+		}
+		if d.Rate > 0 {
+			d.clock.After(d.clock.Cycles(1/d.Rate), done)
+			return
+		}
+		done()
 	}
+}
+
+func (d *Display) write(value byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 
 	// strip bit 7
 	c := value & 0b01111111 // $7F
 
-	fmt.Printf("display: set: $%04x $%02x %v '%v' @ (%v, %v)\n", addr, value, c, string(c), d.col, d.row)
+	logger.Debugf("write: $%02x '%c' @ (%v, %v)", value, c, d.col, d.row)
+	for _, l := range d.listeners {
+		l(c)
+	}
 	d.buffer[d.row*d.cols+d.col] = c
 	d.col++
-	fmt.Printf("display: col: %v\n", d.col)
 	if d.col >= d.cols {
-		d.row++
-		d.col = 0
-		fmt.Printf("display: row: %v\n", d.row)
-		if d.row >= d.rows {
-			d.row--
-			d.buffer = d.buffer[d.cols:]
-			fmt.Printf("display: col: %v, row: %v\n", d.col, d.row)
-		}
+		d.newline()
 	}
 
 	if c == 13 {
-		d.row++
-		d.col = 0
-		if d.row >= d.rows {
-			d.row--
-			d.buffer = d.buffer[d.cols:d.size]
-			for i := 0; i < d.cols; i++ {
-				d.buffer = append(d.buffer, 0x20)
-			}
-			fmt.Printf("display: col: %v, row: %v\n", d.col, d.row)
-		}
-		fmt.Printf("display: col: %v, row: %v\n", d.col, d.row)
-	}
-
-	return nil // never give up, never surrender
-}
-
-func (d *Display) SetWord(addr uint16, value uint16) error {
-	a := addr - d.offset
-	if a+1 >= uint16(d.size) {
-		return fmt.Errorf("Display: invalid offset: %04x", addr)
+		d.newline()
+		logger.Debugf("newline: col: %v, row: %v", d.col, d.row)
 	}
-	d.buffer[a] = uint8(value >> 8)
-	d.buffer[a+1] = uint8(value & 0xFF)
-	return nil
-}
-
-func (d *Display) Get(addr uint16) (byte, error) {
-	if addr == d.offset+1 {
-		return d.mode, nil
-	}
-
-	return 0x00, nil
 }
 
-func (d *Display) GetWord(addr uint16) (uint16, error) {
-	a := addr - d.offset
-	if a+1 >= uint16(d.size) {
-		return 0x00, fmt.Errorf("Display: invalid offset: %04x", addr)
+func (d *Display) newline() {
+	d.row++
+	d.col = 0
+	if d.row >= d.rows {
+		d.row--
+		copy(d.buffer, d.buffer[d.cols:])
+		for i := d.size - d.cols; i < d.size; i++ {
+			d.buffer[i] = 0x20 // Space
+		}
 	}
-	hi := d.buffer[a]
-	lo := d.buffer[a+1]
-	b := (uint16(hi) << 8) + uint16(lo)
-	return b, nil
-}
-
-func (d *Display) Load(bytes []byte) (uint16, error) {
-	return 0x00, fmt.Errorf("not implemented: %v", len(bytes))
 }
@@ -0,0 +1,59 @@
+package Display
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/PIA"
+)
+
+// wozmon sets port B up the way Wozmon does, PB0-PB6 out and CB2 handshaking
+func wozmon(rate float64) (*Display, *PIA.PIA, *IO.Clock) {
+	clock := IO.NewClock(1_000_000)
+	d := New(4, 2, clock)
+	d.Rate = rate
+	pia := PIA.New(0xD010)
+	d.Connect(pia.B)
+	pia.Set(0xD012, 0x7F)
+	pia.Set(0xD013, 0xA7)
+	return d, pia, clock
+}
+
+func busy(pia *PIA.PIA) bool {
+	v, _ := pia.Get(0xD012)
+	return v&0x80 != 0
+}
+
+func TestEcho(t *testing.T) {
+	d, pia, _ := wozmon(0)
+	for _, c := range []byte("HI\rWORLD") {
+		pia.Set(0xD012, 0x80|c)
+		if busy(pia) {
+			t.Fatalf("busy after %q with no char rate", c)
+		}
+	}
+	// the first line scrolled off, and the cursor wrapped past "WORL"
+	if s := d.All(false); s != "WORL\nD   \n" {
+		t.Errorf("screen %q", s)
+	}
+	if col, row := d.Cursor(); col != 1 || row != 1 {
+		t.Errorf("cursor at %v, %v, want 1, 1", col, row)
+	}
+}
+
+func TestRate(t *testing.T) {
+	d, pia, clock := wozmon(1000)
+	pia.Set(0xD012, 0x80|'A')
+	if !busy(pia) {
+		t.Fatal("PB7 not busy with a character going out")
+	}
+	clock.Advance(999)
+	if !busy(pia) || strings.Contains(d.All(false), "A") {
+		t.Fatal("the character went out early")
+	}
+	clock.Advance(1)
+	if busy(pia) || !strings.Contains(d.All(false), "A") {
+		t.Fatal("the character didn't go out after a millisecond")
+	}
+}
@@ -2,11 +2,18 @@ package IO
 
 import (
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zoul0813/go6502/pkg/Debug"
+	"github.com/zoul0813/go6502/pkg/Log"
 )
 
+var logger = Log.For("io")
+
 type Memory interface {
 	Set(addr uint16, value byte) error
 	SetWord(addr uint16, value uint16) error
@@ -16,22 +23,167 @@ type Memory interface {
 	Size() uint16
 }
 
+// Fetcher is a Memory that can tell an opcode fetch from any other read,
+// the CPU uses Fetch when it's there
+type Fetcher interface {
+	Fetch(addr uint16) (byte, error)
+}
+
+// Peeker is a chip that can be read without side effects, IO.Peek uses it
+// when it's there
+type Peeker interface {
+	Peek(addr uint16) (byte, error)
+}
+
+// Poker is a chip that can be written past its read only flag, LoadAt uses
+// it when it's there
+type Poker interface {
+	Poke(addr uint16, value byte) error
+}
+
+// Banked is a chip with several banks behind its address window, see Bank
+type Banked interface {
+	Banks() int
+	Active() int
+	PeekBank(bank int, addr uint16) (byte, error)
+}
+
 type Device struct {
 	Name   string
 	Chip   Memory
 	Size   uint16
 	Offset uint16
+	Rules  []Rule
+}
+
+// Rule is an extra, partially decoded, address range for a device.
+//
+// The device is selected for every address where addr & Mask == Match and
+// the chip is handed Offset + (addr & Lines), the address lines it is
+// wired to.  Lines in neither Mask nor Lines aren't decoded at all, which
+// is what makes a device show up mirrored, e.g. the Apple-1 PIA:
+//
+//	pia.Decode(0xD010, 0xFF10, 0x0003) // $D010-3, mirrored in $D0xx wherever A4 is set
+type Rule struct {
+	Match uint16
+	Mask  uint16
+	Lines uint16
+}
+
+// Policy is what the bus does for an address no device answers
+type Policy int
+
+const (
+	OpenBus Policy = iota // reads see the last byte on the bus, writes go nowhere
+	Fixed                 // reads see Unmapped.Value
+	Fault                 // OnFault is called and the access fails
+)
+
+type Unmapped struct {
+	Policy Policy
+	Value  byte
+}
+
+// ParseUnmapped reads "openbus", "fixed:EA" or "fault"
+func ParseUnmapped(s string) (Unmapped, error) {
+	name, value, found := strings.Cut(strings.ToLower(s), ":")
+	switch name {
+	case "", "openbus":
+		if !found {
+			return Unmapped{Policy: OpenBus}, nil
+		}
+	case "fixed":
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(value, "$"), "0x"), 16, 8)
+		if err != nil {
+			return Unmapped{}, fmt.Errorf("invalid fixed value %q, expected a hex byte", value)
+		}
+		return Unmapped{Policy: Fixed, Value: byte(v)}, nil
+	case "fault":
+		if !found {
+			return Unmapped{Policy: Fault}, nil
+		}
+	}
+	return Unmapped{}, fmt.Errorf("invalid unmapped policy %q, expected openbus, fixed:XX or fault", s)
+}
+
+func (u Unmapped) String() string {
+	switch u.Policy {
+	case Fixed:
+		return fmt.Sprintf("fixed:%02X", u.Value)
+	case Fault:
+		return "fault"
+	}
+	return "openbus"
 }
 
 type IO struct {
-	Devices []*Device
+	Devices  []*Device
+	Unmapped Unmapped
+	// OnFault is called for unmapped accesses under the Fault policy
+	OnFault func(addr uint16, write bool)
 	mutex   sync.Mutex
+	pages   [256]page
+	bus     byte // the last byte driven on the data bus
+	pc      uint16
+	hooks   []*hook
+	watch   atomic.Pointer[[256]Access] // the accesses hooked in each page
+	hookId  int
+}
+
+// target is where an address decodes to, the chip sees Base + (addr & Lines)
+type target struct {
+	device *Device
+	base   uint16
+	lines  uint16
+}
+
+// page decodes 256 bytes of the address space, most pages belong to a
+// single device; pages shared by small devices (like the PIA at $D010)
+// get a table with an entry per address
+type page struct {
+	target
+	sub []target
 }
 
+// New builds the page table up front, every access after that is a single
+// lookup.  Where devices overlap the one listed first wins, so Devices must
+// not be changed once the IO has been created.
 func New(devices []*Device) *IO {
-	return &IO{
+	io := &IO{
 		Devices: devices,
 	}
+	for p := range io.pages {
+		var sub [256]target
+		shared := false
+		for a := range sub {
+			sub[a] = find(devices, uint16(p<<8|a))
+			// a page of mirrors still differs address by address, a page
+			// nothing decodes is a single unmapped target
+			if sub[a] != sub[0] || (sub[a].device != nil && sub[a].lines != 0xFFFF) {
+				shared = true
+			}
+		}
+		if shared {
+			io.pages[p].sub = sub[:]
+		} else {
+			io.pages[p].target = sub[0]
+		}
+	}
+	return io
+}
+
+func find(devices []*Device, addr uint16) target {
+	for _, d := range devices {
+		if int(addr) >= int(d.Offset) && int(addr) <= int(d.Offset)+int(d.Size) {
+			return target{device: d, base: 0, lines: 0xFFFF}
+		}
+		for _, r := range d.Rules {
+			if addr&r.Mask == r.Match {
+				return target{device: d, base: d.Offset, lines: r.Lines}
+			}
+		}
+	}
+	return target{}
 }
 
 func NewDevice(name string, chip Memory, offset uint16) *Device {
@@ -43,127 +195,293 @@ func NewDevice(name string, chip Memory, offset uint16) *Device {
 	}
 }
 
+// Decode adds a partially decoded address range to the device, see Rule
+func (d *Device) Decode(match uint16, mask uint16, lines uint16) *Device {
+	d.Rules = append(d.Rules, Rule{
+		Match: match,
+		Mask:  mask,
+		Lines: lines,
+	})
+	return d
+}
+
 func (io *IO) List() {
 	for _, d := range io.Devices {
 		fmt.Printf("%v: %v ($%04x)\n", d.Name, d.Size, d.Offset)
+		if b, ok := d.Chip.(Banked); ok {
+			fmt.Printf("\tbank %v of %v\n", b.Active(), b.Banks())
+		}
+		for _, r := range d.Rules {
+			fmt.Printf("\tmatch $%04x mask $%04x lines $%04x\n", r.Match, r.Mask, r.Lines)
+		}
 	}
 }
 
-func (io *IO) getDevice(addr uint16) (*Device, error) {
-	var device *Device
-	for _, d := range io.Devices {
-		size := int(d.Offset) + int(d.Size)
-		// fmt.Printf("\tTesting: %v $%04x at $%04x with $%04x for %v ($%04x)\n", d.Name, addr, d.Offset, size, d.Size, d.Size)
-		if addr >= d.Offset && int(addr) <= size {
-			// fmt.Printf("\tFound: %v\n", d.Name)
-			device = d
-			break
-		}
+// getDevice returns the device for addr, and the address its chip sees
+func (io *IO) getDevice(addr uint16) (*Device, uint16, error) {
+	p := &io.pages[addr>>8]
+	t := &p.target
+	if p.sub != nil {
+		t = &p.sub[addr&0xFF]
 	}
-
-	var err error = nil
-	if device == nil {
-		// fmt.Printf("\tDevice not found for address $%04x\n", addr)
-		err = fmt.Errorf("Device not found for address $%04x", addr)
-	} else {
-		// fmt.Printf("\tDevice: %v\n", device.Name)
+	if t.device == nil {
+		return nil, addr, fmt.Errorf("Device not found for address $%04x", addr)
 	}
-	return device, err
+	return t.device, t.base + (addr & t.lines), nil
 }
 
 func (io *IO) Size() uint16 {
 	return 0xFFFF
 }
 
+// unmapped handles an access nobody answered, returning what a read sees
+func (io *IO) unmapped(addr uint16, write bool, err error) (byte, error) {
+	switch io.Unmapped.Policy {
+	case Fixed:
+		return io.Unmapped.Value, nil
+	case Fault:
+		if io.OnFault != nil {
+			io.OnFault(addr, write)
+		}
+		return io.Bus(), err
+	}
+	return io.Bus(), nil
+}
+
 func (io *IO) Set(addr uint16, value byte) error {
-	var err error
-	device, err := io.getDevice(addr)
+	err := io.set(addr, value)
+	io.watched(Write, addr, value)
+	return err
+}
+
+func (io *IO) set(addr uint16, value byte) error {
+	device, chipAddr, err := io.getDevice(addr)
 	if err != nil {
-		fmt.Printf("Error: Device not found: %v\n", err)
+		if logger.On(slog.LevelDebug) {
+			logger.Debugf("write $%02x -> $%04x: %v", value, addr, err)
+		}
+		io.drive(value)
+		_, err = io.unmapped(addr, true, err)
 		return err
 	}
-	fmt.Printf("Found: %v, locking...", device.Name)
-	fmt.Printf(" %02x -> %04x...\n", value, addr)
+	if logger.On(slog.LevelDebug) {
+		logger.Debugf("write $%02x -> $%04x (%v)", value, addr, device.Name)
+	}
 	chip := device.Chip
 	io.mutex.Lock()
 	defer io.mutex.Unlock()
-	err = chip.Set(addr, value)
-	// device.Chip = &chip
-	fmt.Print(" Done\n")
-	return err
+	io.bus = value
+	return chip.Set(chipAddr, value)
 }
 
 func (io *IO) SetWord(addr uint16, value uint16) error {
-	var err error
-	device, err := io.getDevice(addr)
+	device, chipAddr, err := io.getDevice(addr)
 	if err != nil {
-		return err
+		io.drive(byte(value >> 8))
+		_, err = io.unmapped(addr, true, err)
+	} else {
+		chip := device.Chip
+		io.mutex.Lock()
+		io.bus = byte(value >> 8)
+		err = chip.SetWord(chipAddr, value)
+		io.mutex.Unlock()
 	}
-	chip := device.Chip
-	io.mutex.Lock()
-	defer io.mutex.Unlock()
-	err = chip.SetWord(addr, value)
-	// device.Chip = &chip
+	io.watched(Write, addr, byte(value))
+	io.watched(Write, addr+1, byte(value>>8))
 	return err
 }
 
 func (io *IO) Get(addr uint16) (byte, error) {
-	var err error
-	device, err := io.getDevice(addr)
+	b, err := io.get(addr)
+	io.watched(Read, addr, b)
+	return b, err
+}
+
+// Fetch is Get for an opcode, it runs Exec hooks instead of Read hooks and
+// marks addr as the PC hooks are handed
+func (io *IO) Fetch(addr uint16) (byte, error) {
+	b, err := io.get(addr)
+	io.pc = addr
+	io.watched(Exec, addr, b)
+	return b, err
+}
+
+func (io *IO) get(addr uint16) (byte, error) {
+	device, chipAddr, err := io.getDevice(addr)
 	if err != nil {
-		return 0xEA, err
+		return io.unmapped(addr, false, err)
 	}
 	chip := device.Chip
 	io.mutex.Lock()
 	defer io.mutex.Unlock()
-	return chip.Get(addr)
+	b, err := chip.Get(chipAddr)
+	io.bus = b
+	return b, err
 }
 
 func (io *IO) GetWord(addr uint16) (uint16, error) {
-	var err error
-	device, err := io.getDevice(addr)
+	var w uint16
+	device, chipAddr, err := io.getDevice(addr)
 	if err != nil {
-		return 0xEAEA, err
+		var b byte
+		b, err = io.unmapped(addr, false, err)
+		w = uint16(b)<<8 | uint16(b)
+	} else {
+		chip := device.Chip
+		io.mutex.Lock()
+		w, err = chip.GetWord(chipAddr)
+		io.bus = byte(w >> 8)
+		io.mutex.Unlock()
 	}
-	chip := device.Chip
+	io.watched(Read, addr, byte(w))
+	io.watched(Read, addr+1, byte(w>>8))
+	return w, err
+}
+
+// Peek reads addr for a debugger or a dump, it doesn't drive the bus or
+// fault.  Unmapped addresses read as the current bus value.
+func (io *IO) Peek(addr uint16) (byte, error) {
+	device, addr, err := io.getDevice(addr)
 	io.mutex.Lock()
 	defer io.mutex.Unlock()
-	return chip.GetWord(addr)
+	if err != nil {
+		if io.Unmapped.Policy == Fixed {
+			return io.Unmapped.Value, err
+		}
+		return io.bus, err
+	}
+	if p, ok := device.Chip.(Peeker); ok {
+		return p.Peek(addr)
+	}
+	return device.Chip.Get(addr)
+}
+
+// PeekBank is Peek for a specific bank of a banked device, other devices
+// ignore bank
+func (io *IO) PeekBank(bank int, addr uint16) (byte, error) {
+	device, addr, err := io.getDevice(addr)
+	if err != nil {
+		return io.Peek(addr)
+	}
+	b, ok := device.Chip.(Banked)
+	if !ok {
+		return io.Peek(addr)
+	}
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	return b.PeekBank(bank, addr)
+}
+
+// Bus returns the last byte driven on the data bus
+func (io *IO) Bus() byte {
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	return io.bus
+}
+
+func (io *IO) drive(value byte) {
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	io.bus = value
 }
 
 func (io *IO) Load(bytes []byte) (uint16, error) {
 	return 0x00, fmt.Errorf("not implemented: %v", len(bytes))
 }
 
+// LoadRom loads bytes at offset, see LoadAt
 func (io *IO) LoadRom(bytes []byte, offset uint16) (uint16, error) {
-	// return 0, fmt.Errorf("not implemented: %v", len(bytes))
+	n, err := io.LoadAt(offset, bytes)
+	return uint16(n), err
+}
+
+// LoadError is the part of a LoadAt that didn't land
+type LoadError struct {
+	Loaded int
+	Total  int
+	Gaps   []Gap
+}
+
+// Gap is a run of addresses a load couldn't write
+type Gap struct {
+	Start uint16
+	End   uint16
+	Err   error
+}
 
-	size := io.Size()
-	if len(bytes) > int(size-offset)+1 {
-		fmt.Printf("%04x is too large for ROM with %04x\n", len(bytes), io.Size())
-		return 0, fmt.Errorf("%04x is too large for ROM with %04x", len(bytes), io.Size())
+func (e *LoadError) Error() string {
+	s := fmt.Sprintf("loaded %v of %v bytes", e.Loaded, e.Total)
+	for _, g := range e.Gaps {
+		s += fmt.Sprintf(", $%04x-$%04x: %v", g.Start, g.End, g.Err)
 	}
+	return s
+}
 
-	device, err := io.getDevice(offset)
-	if err != nil {
-		fmt.Printf("Load Error: Device Not Found: %v\n", err)
-		return 0, err
+// LoadAt writes bytes into memory from addr up, the way a program loader
+// would: it carries on across device boundaries, writes ROM as well as RAM
+// and doesn't go over the bus (no hooks, the bus value is left alone).
+//
+// Whatever can't be written, unmapped addresses or devices that refuse, is
+// skipped and listed in a *LoadError along with how much did land.
+func (io *IO) LoadAt(addr uint16, bytes []byte) (int, error) {
+	if int(addr)+len(bytes) > 0x10000 {
+		return 0, fmt.Errorf("%v bytes at $%04x runs past $FFFF", len(bytes), addr)
 	}
 
-	// fmt.Printf("Loading %v bytes into %v at %v ($%04x)\n", len(bytes), device.Name, offset, offset)
-	loadedSize, err := device.Chip.Load(bytes)
+	e := &LoadError{Total: len(bytes)}
+	for i, b := range bytes {
+		a := addr + uint16(i)
+		err := io.poke(a, b)
+		if err == nil {
+			e.Loaded++
+			continue
+		}
+		// runs of addresses failing the same way are a single gap
+		if n := len(e.Gaps); n > 0 && e.Gaps[n-1].End == a-1 && e.Gaps[n-1].Err.Error() == err.Error() {
+			e.Gaps[n-1].End = a
+			continue
+		}
+		e.Gaps = append(e.Gaps, Gap{Start: a, End: a, Err: err})
+	}
+	logger.Infof("loaded %v of %v bytes at $%04x", e.Loaded, e.Total, addr)
+	if len(e.Gaps) > 0 {
+		return e.Loaded, e
+	}
+	return e.Loaded, nil
+}
 
-	return loadedSize, err
+func (io *IO) poke(addr uint16, value byte) error {
+	device, chipAddr, err := io.getDevice(addr)
+	if err != nil {
+		return fmt.Errorf("unmapped")
+	}
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	if p, ok := device.Chip.(Poker); ok {
+		return p.Poke(chipAddr, value)
+	}
+	return device.Chip.Set(chipAddr, value)
 }
 
 func (io *IO) Dump(addr uint16, size uint16) {
+	io.dump(addr, size, "", io.Peek)
+}
+
+// DumpBank dumps addr from a specific bank, see PeekBank
+func (io *IO) DumpBank(bank int, addr uint16, size uint16) {
+	io.dump(addr, size, fmt.Sprintf("%v:", bank), func(a uint16) (byte, error) {
+		return io.PeekBank(bank, a)
+	})
+}
+
+func (io *IO) dump(addr uint16, size uint16, prefix string, peek func(uint16) (byte, error)) {
 	a := addr
 	if int(a+size) > int(io.Size()) {
 		fmt.Printf("%04x is out of range of %04x", addr, io.Size())
 		return
 	}
 
-	fmt.Printf("Memory Dump (%04x:%04x)\n", addr, addr+size)
+	fmt.Printf("Memory Dump (%v%04x:%04x)\n", prefix, addr, addr+size)
 	fmt.Print(Debug.Colorize(Debug.DebugColor, "%s", "---- 0001 0203 0405 0607 0809 0A0B 0C0D 0E0F\n"))
 	fmt.Print(Debug.Colorize(Debug.DebugColor, "%s", "---- ---- ---- ---- ---- ---- ---- ---- ----\n"))
 	// fmt.Printf("0000000 2aa5 3818 0000 0000 0000 0000 0000 0000")
@@ -174,9 +492,9 @@ func (io *IO) Dump(addr uint16, size uint16) {
 		fmt.Print(Debug.Colorize(Debug.AddrColor, "%04x ", i))
 		for w := 0; w < 8; w++ {
 			// fmt.Printf("%02x %02x ", i, i+1)
-			w1, _ := io.Get(i)
+			w1, _ := peek(i)
 			i++
-			w2, _ := io.Get(i)
+			w2, _ := peek(i)
 			if i < end {
 				i++
 			}
@@ -201,6 +519,17 @@ func (io *IO) Dump(addr uint16, size uint16) {
 }
 
 func (io *IO) DumpString(addr uint16, size uint16) string {
+	return io.dumpString(addr, size, "", io.Peek)
+}
+
+// DumpBankString is DumpString for a specific bank, see PeekBank
+func (io *IO) DumpBankString(bank int, addr uint16, size uint16) string {
+	return io.dumpString(addr, size, fmt.Sprintf("%v:", bank), func(a uint16) (byte, error) {
+		return io.PeekBank(bank, a)
+	})
+}
+
+func (io *IO) dumpString(addr uint16, size uint16, prefix string, peek func(uint16) (byte, error)) string {
 	a := addr
 	s := ""
 	if int(a+size) > int(io.Size()) {
@@ -208,7 +537,7 @@ func (io *IO) DumpString(addr uint16, size uint16) string {
 		return s
 	}
 
-	s += fmt.Sprintf("Memory Dump (%04x:%04x)\n", addr, addr+size)
+	s += fmt.Sprintf("Memory Dump (%v%04x:%04x)\n", prefix, addr, addr+size)
 	s += "ADDR 0001 0203 0405 0607 0809 0A0B 0C0D 0E0F\n"
 	s += "---- ---- ---- ---- ---- ---- ---- ---- ----\n"
 	// fmt.Printf("0000000 2aa5 3818 0000 0000 0000 0000 0000 0000")
@@ -218,9 +547,9 @@ func (io *IO) DumpString(addr uint16, size uint16) string {
 	for i < end {
 		s += fmt.Sprintf("%04x ", i)
 		for w := 0; w < 8; w++ {
-			w1, _ := io.Get(i)
+			w1, _ := peek(i)
 			i++
-			w2, _ := io.Get(i)
+			w2, _ := peek(i)
 			if i < end {
 				i++
 			}
@@ -0,0 +1,214 @@
+package IO_test
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Memory"
+)
+
+// apple1 is the Apple-1's map, RAM at the bottom, the PIA's page and the
+// ROM at the top, so decoding has to get past a few devices
+func apple1() *IO.IO {
+	return IO.New([]*IO.Device{
+		IO.NewDevice("RAM", Memory.New(0x7FFF, 0x0000, false), 0x0000),
+		IO.NewDevice("PIA", Memory.New(0x0003, 0xD010, false), 0xD010),
+		IO.NewDevice("ROM", Memory.New(0x0FFF, 0xF000, true), 0xF000),
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	io := apple1()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Get(uint16(i) & 0x7FFF)
+	}
+}
+
+func BenchmarkGetROM(b *testing.B) {
+	io := apple1()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Get(0xF000 | uint16(i)&0x0FFF)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	io := apple1()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Set(uint16(i)&0x7FFF, byte(i))
+	}
+}
+
+func TestDecodeMirrors(t *testing.T) {
+	pia := Memory.New(0x0003, 0xD010, false)
+	io := IO.New([]*IO.Device{
+		IO.NewDevice("PIA", pia, 0xD010).Decode(0xD010, 0xFF10, 0x0003),
+		IO.NewDevice("RAM", Memory.New(0xFFFF, 0x0000, false), 0x0000),
+	})
+
+	io.Set(0xD0F2, 0x42)
+	if pia.Bytes[2] != 0x42 {
+		t.Fatalf("$D0F2 wrote $%02x to the PIA's register 2, want $42", pia.Bytes[2])
+	}
+	for _, addr := range []uint16{0xD012, 0xD016, 0xD01E, 0xD0F2} {
+		if v, _ := io.Get(addr); v != 0x42 {
+			t.Errorf("$%04x read $%02x, want the PIA's $42", addr, v)
+		}
+	}
+	// A4 clear, not the PIA's, so RAM behind it shows through
+	io.Set(0xD002, 0x99)
+	if pia.Bytes[2] != 0x42 {
+		t.Fatal("$D002 decoded to the PIA")
+	}
+	if v, _ := io.Get(0xD002); v != 0x99 {
+		t.Fatalf("$D002 read $%02x, want RAM's $99", v)
+	}
+}
+
+func TestFirstDeviceWins(t *testing.T) {
+	rom := Memory.New(0x00FF, 0xFF00, true)
+	rom.Bytes[0xFC] = 0x5A
+	io := IO.New([]*IO.Device{
+		IO.NewDevice("ROM", rom, 0xFF00),
+		IO.NewDevice("RAM", Memory.New(0xFFFF, 0x0000, false), 0x0000),
+	})
+	if v, _ := io.Get(0xFFFC); v != 0x5A {
+		t.Fatalf("$FFFC read $%02x from under the ROM, want $5A", v)
+	}
+}
+
+func TestUnmapped(t *testing.T) {
+	ram := Memory.New(0x00FF, 0x0000, false)
+	ram.Bytes[0x10] = 0xA9
+	io := IO.New([]*IO.Device{IO.NewDevice("RAM", ram, 0x0000)})
+
+	// open bus, the last byte read is still on the lines
+	io.Get(0x0010)
+	if v, err := io.Get(0x8000); v != 0xA9 || err != nil {
+		t.Errorf("open bus read $%02x, %v, want $A9", v, err)
+	}
+
+	io.Unmapped, _ = IO.ParseUnmapped("fixed:EA")
+	if v, err := io.Get(0x8000); v != 0xEA || err != nil {
+		t.Errorf("fixed read $%02x, %v, want $EA", v, err)
+	}
+
+	io.Unmapped, _ = IO.ParseUnmapped("fault")
+	var faults []uint16
+	io.OnFault = func(addr uint16, write bool) {
+		faults = append(faults, addr)
+	}
+	if _, err := io.Get(0x8000); err == nil {
+		t.Error("faulting read didn't fail")
+	}
+	if err := io.Set(0x9000, 0x00); err == nil {
+		t.Error("faulting write didn't fail")
+	}
+	if len(faults) != 2 || faults[0] != 0x8000 || faults[1] != 0x9000 {
+		t.Errorf("faulted at %04x, want [8000 9000]", faults)
+	}
+}
+
+func TestParseUnmapped(t *testing.T) {
+	for s, want := range map[string]string{
+		"":         "openbus",
+		"OpenBus":  "openbus",
+		"fixed:ff": "fixed:FF",
+		"fixed:$0": "fixed:00",
+		"fault":    "fault",
+	} {
+		u, err := IO.ParseUnmapped(s)
+		if err != nil || u.String() != want {
+			t.Errorf("%q parsed as %v, %v, want %v", s, u, err, want)
+		}
+	}
+	for _, s := range []string{"fixed", "fixed:100", "fault:1", "ignore"} {
+		if _, err := IO.ParseUnmapped(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	io := apple1()
+	type access struct {
+		access IO.Access
+		addr   uint16
+		value  byte
+		pc     uint16
+	}
+	var seen []access
+	id := io.AddHook(IO.Write|IO.Exec, 0x0024, 0x0025, func(a IO.Access, addr uint16, value byte, pc uint16) {
+		seen = append(seen, access{a, addr, value, pc})
+	})
+
+	io.Fetch(0x0300)
+	io.Set(0x0023, 0x01) // outside the range
+	io.Set(0x0024, 0x02)
+	io.Get(0x0025) // reads aren't hooked
+	io.Fetch(0x0025)
+	want := []access{{IO.Write, 0x0024, 0x02, 0x0300}, {IO.Exec, 0x0025, 0x00, 0x0025}}
+	if len(seen) != len(want) {
+		t.Fatalf("hooked %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("hook %v saw %v, want %v", i, seen[i], want[i])
+		}
+	}
+
+	io.RemoveHook(id)
+	io.Set(0x0024, 0x03)
+	if len(seen) != len(want) {
+		t.Error("hook ran after it was removed")
+	}
+}
+
+func TestParseAccess(t *testing.T) {
+	for s, want := range map[string]IO.Access{"r": IO.Read, "W": IO.Write, "rx": IO.Read | IO.Exec} {
+		if a, ok := IO.ParseAccess(s); !ok || a != want {
+			t.Errorf("%q parsed as %v, want %v", s, a, want)
+		}
+	}
+	for _, s := range []string{"", "q", "rwz"} {
+		if _, ok := IO.ParseAccess(s); ok {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+func TestLoadAt(t *testing.T) {
+	io := apple1()
+	hooked := false
+	io.AddHook(IO.Write, 0x0000, 0xFFFF, func(IO.Access, uint16, byte, uint16) {
+		hooked = true
+	})
+
+	if n, err := io.LoadAt(0xFFFC, []byte{0x00, 0xFF, 0x00, 0x00}); n != 4 || err != nil {
+		t.Fatalf("loaded %v into ROM, %v", n, err)
+	}
+	if v, _ := io.GetWord(0xFFFC); v != 0xFF00 {
+		t.Errorf("reset vector $%04x, want $FF00", v)
+	}
+
+	n, err := io.LoadAt(0x7FFE, []byte{1, 2, 3, 4, 5})
+	e, ok := err.(*IO.LoadError)
+	if n != 2 || !ok {
+		t.Fatalf("loaded %v across the end of RAM, %v", n, err)
+	}
+	if len(e.Gaps) != 1 || e.Gaps[0].Start != 0x8000 || e.Gaps[0].End != 0x8002 {
+		t.Errorf("gaps %v, want $8000-$8002", e.Gaps)
+	}
+	if hooked {
+		t.Error("loading went over the bus")
+	}
+
+	if _, err := io.LoadAt(0xFFFF, []byte{1, 2}); err == nil {
+		t.Error("loaded past $FFFF")
+	}
+}
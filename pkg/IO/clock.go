@@ -0,0 +1,131 @@
+package IO
+
+import (
+	"container/heap"
+)
+
+// Ticker is a chip that keeps time, it's told the CPU cycles that have
+// gone by after every instruction
+type Ticker interface {
+	Tick(cycles int)
+}
+
+/*
+	Clock is the machine's sense of time, in CPU cycles.  The run loop
+	Advances it after every instruction, it ticks the Tickers and runs the
+	events that have come due, in the order they're due.  A chip that only
+	has something to do now and then, a character finishing on a serial
+	line, schedules an event instead of counting down every cycle.
+
+	Events run on the CPU goroutine between instructions, with Now at the
+	cycle they were due, and can schedule more.
+*/
+
+type Clock struct {
+	Hz float64 // CPU cycles per second
+
+	now     uint64
+	tickers []Ticker
+	events  events
+	seq     uint64
+}
+
+type Event struct {
+	at    uint64
+	seq   uint64 // events due on the same cycle run in the order they were made
+	f     func()
+	index int // in the heap, -1 once it's run or cancelled
+}
+
+func NewClock(hz float64) *Clock {
+	return &Clock{Hz: hz}
+}
+
+// Now is the cycle count
+func (c *Clock) Now() uint64 {
+	return c.now
+}
+
+// Cycles is how many cycles seconds takes, at least one
+func (c *Clock) Cycles(seconds float64) uint64 {
+	return max(1, uint64(seconds*c.Hz))
+}
+
+// Add ticks t from now on
+func (c *Clock) Add(t Ticker) {
+	c.tickers = append(c.tickers, t)
+}
+
+// At runs f at cycle, straight away at the next Advance if that's gone
+func (c *Clock) At(cycle uint64, f func()) *Event {
+	e := &Event{at: cycle, seq: c.seq, f: f}
+	c.seq++
+	heap.Push(&c.events, e)
+	return e
+}
+
+// After runs f cycles from now
+func (c *Clock) After(cycles uint64, f func()) *Event {
+	return c.At(c.now+cycles, f)
+}
+
+// Cancel stops e running, it's fine to cancel an event that has run
+func (c *Clock) Cancel(e *Event) {
+	if e != nil && e.index >= 0 {
+		heap.Remove(&c.events, e.index)
+	}
+}
+
+// Pending is true until e has run or been cancelled
+func (e *Event) Pending() bool {
+	return e != nil && e.index >= 0
+}
+
+// Advance moves time on by cycles
+func (c *Clock) Advance(cycles uint64) {
+	for _, t := range c.tickers {
+		t.Tick(int(cycles))
+	}
+	end := c.now + cycles
+	for len(c.events) > 0 && c.events[0].at <= end {
+		e := heap.Pop(&c.events).(*Event)
+		c.now = max(c.now, e.at)
+		e.f()
+	}
+	c.now = end
+}
+
+// events is a container/heap, soonest first
+type events []*Event
+
+func (q events) Len() int {
+	return len(q)
+}
+
+func (q events) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q events) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *events) Push(x any) {
+	e := x.(*Event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *events) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,72 @@
+package IO_test
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+type ticker struct {
+	cycles int
+}
+
+func (t *ticker) Tick(cycles int) {
+	t.cycles += cycles
+}
+
+func TestEvents(t *testing.T) {
+	c := IO.NewClock(1_000_000)
+	var ran []string
+	at := func(name string) func() {
+		return func() {
+			ran = append(ran, name)
+		}
+	}
+	c.At(30, at("c"))
+	c.At(10, at("a"))
+	c.At(10, at("b")) // the same cycle, after a
+	cancelled := c.At(20, at("cancelled"))
+	c.At(40, func() {
+		ran = append(ran, "d")
+		if c.Now() != 40 {
+			t.Errorf("d ran at %v, want 40", c.Now())
+		}
+		c.After(0, at("e")) // scheduled by an event, still due now
+	})
+	c.Cancel(cancelled)
+	if cancelled.Pending() {
+		t.Error("pending after it was cancelled")
+	}
+
+	c.Advance(35)
+	c.Advance(5)
+	if c.Now() != 40 {
+		t.Errorf("now %v, want 40", c.Now())
+	}
+	want := "abcde"
+	got := ""
+	for _, s := range ran {
+		got += s
+	}
+	if got != want {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+	c.Cancel(cancelled) // twice is fine
+}
+
+func TestTickers(t *testing.T) {
+	c := IO.NewClock(2_000_000)
+	tk := &ticker{}
+	c.Add(tk)
+	c.Advance(7)
+	c.Advance(3)
+	if tk.cycles != 10 {
+		t.Errorf("ticked %v cycles, want 10", tk.cycles)
+	}
+	if n := c.Cycles(0.001); n != 2000 {
+		t.Errorf("a millisecond is %v cycles, want 2000", n)
+	}
+	if n := c.Cycles(0); n != 1 {
+		t.Errorf("no time is %v cycles, want at least 1", n)
+	}
+}
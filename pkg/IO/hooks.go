@@ -0,0 +1,135 @@
+package IO
+
+import "strings"
+
+/*
+	Hooks watch the bus, a hook is called after every matching access in
+	its address range with the byte that went over the bus and the PC of
+	the instruction doing it:
+
+	id := io.AddHook(IO.Write, 0x0024, 0x0025, func(a IO.Access, addr uint16, value byte, pc uint16) {
+		fmt.Printf("%v $%02x -> $%04x at $%04x\n", a, value, addr, pc)
+	})
+	...
+	io.RemoveHook(id)
+
+	Hooks run on the CPU's goroutine with the machine stopped, they can read
+	and write through the IO but shouldn't block.  Exec hooks need a CPU that
+	fetches opcodes with Fetch.
+*/
+
+type Access uint8
+
+const (
+	Read Access = 1 << iota
+	Write
+	Exec
+)
+
+// ParseAccess reads any of "r", "w" and "x", e.g. "rw"
+func ParseAccess(s string) (Access, bool) {
+	var a Access
+	for _, c := range strings.ToLower(s) {
+		switch c {
+		case 'r':
+			a |= Read
+		case 'w':
+			a |= Write
+		case 'x':
+			a |= Exec
+		default:
+			return 0, false
+		}
+	}
+	return a, a != 0
+}
+
+func (a Access) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Exec:
+		return "exec"
+	}
+	s := ""
+	for i, c := range "rwx" {
+		if a&(1<<i) != 0 {
+			s += string(c)
+		}
+	}
+	return s
+}
+
+type Hook func(access Access, addr uint16, value byte, pc uint16)
+
+type hook struct {
+	id     int
+	access Access
+	start  uint16
+	end    uint16
+	f      Hook
+}
+
+// AddHook calls f for every access in access from start to end inclusive,
+// the returned id removes it again
+func (io *IO) AddHook(access Access, start uint16, end uint16, f Hook) int {
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	io.hookId++
+	h := &hook{
+		id:     io.hookId,
+		access: access,
+		start:  start,
+		end:    end,
+		f:      f,
+	}
+	// copied, so watched can walk the old list without holding the lock
+	io.hooks = append(append([]*hook{}, io.hooks...), h)
+	io.rewatch()
+	return h.id
+}
+
+func (io *IO) RemoveHook(id int) {
+	io.mutex.Lock()
+	defer io.mutex.Unlock()
+	hooks := make([]*hook, 0, len(io.hooks))
+	for _, h := range io.hooks {
+		if h.id != id {
+			hooks = append(hooks, h)
+		}
+	}
+	io.hooks = hooks
+	io.rewatch()
+}
+
+func (io *IO) rewatch() {
+	watch := &[256]Access{}
+	for _, h := range io.hooks {
+		for p := int(h.start >> 8); p <= int(h.end>>8); p++ {
+			watch[p] |= h.access
+		}
+	}
+	io.watch.Store(watch)
+}
+
+// PC is the address of the last opcode fetched, the instruction running
+func (io *IO) PC() uint16 {
+	return io.pc
+}
+
+func (io *IO) watched(access Access, addr uint16, value byte) {
+	watch := io.watch.Load()
+	if watch == nil || watch[addr>>8]&access == 0 {
+		return
+	}
+	io.mutex.Lock()
+	hooks := io.hooks
+	io.mutex.Unlock()
+	for _, h := range hooks {
+		if h.access&access != 0 && addr >= h.start && addr <= h.end {
+			h.f(access, addr, value, io.pc)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package IO
+
+/*
+	Interrupts are the CPU's IRQ and NMI lines and the sources that can
+	pull them low.  Each source is asserted and released by its ID, the
+	device behind it calls Set whenever its output changes.  IRQ is low
+	while any of its sources is, a wired-OR like the open collector
+	outputs on the real board.  NMI is edge triggered, the CPU sees it once
+	each time the line goes from released to asserted, however long it's
+	held.
+
+	The CPU samples the lines between instructions.  Fired is told every
+	time a source starts asserting, the debugger uses it to break on a
+	source.
+*/
+
+type Interrupts struct {
+	Fired func(s *Source)
+
+	sources []*Source
+	nmi     bool // the NMI line when it was last looked at
+	edge    bool // an NMI edge the CPU hasn't taken yet
+}
+
+type Source struct {
+	ID       int
+	Name     string
+	NMI      bool // on the NMI line instead of IRQ
+	Asserted bool
+	Count    int // times it has started asserting
+}
+
+func NewInterrupts() *Interrupts {
+	return &Interrupts{}
+}
+
+// Add makes a source on the IRQ line, or NMI, and returns its ID
+func (i *Interrupts) Add(name string, nmi bool) int {
+	s := &Source{ID: len(i.sources), Name: name, NMI: nmi}
+	i.sources = append(i.sources, s)
+	return s.ID
+}
+
+// Set asserts or releases the source
+func (i *Interrupts) Set(id int, asserted bool) {
+	s := i.sources[id]
+	if s.Asserted == asserted {
+		return
+	}
+	s.Asserted = asserted
+	if s.NMI {
+		i.sample()
+	}
+	if asserted {
+		s.Count++
+		if i.Fired != nil {
+			i.Fired(s)
+		}
+	}
+}
+
+// IRQ is the level of the IRQ line, true when it's asserted
+func (i *Interrupts) IRQ() bool {
+	for _, s := range i.sources {
+		if s.Asserted && !s.NMI {
+			return true
+		}
+	}
+	return false
+}
+
+// NMI is true once for each time the NMI line has been asserted
+func (i *Interrupts) NMI() bool {
+	edge := i.edge
+	i.edge = false
+	return edge
+}
+
+// Asserting lists the sources holding a line low
+func (i *Interrupts) Asserting() []*Source {
+	var list []*Source
+	for _, s := range i.sources {
+		if s.Asserted {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func (i *Interrupts) Sources() []*Source {
+	return i.sources
+}
+
+// Find looks a source up by name
+func (i *Interrupts) Find(name string) *Source {
+	for _, s := range i.sources {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// sample watches the NMI line for edges
+func (i *Interrupts) sample() {
+	level := false
+	for _, s := range i.sources {
+		if s.Asserted && s.NMI {
+			level = true
+		}
+	}
+	if level && !i.nmi {
+		i.edge = true
+	}
+	i.nmi = level
+}
@@ -0,0 +1,75 @@
+package IO_test
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+func TestWiredOR(t *testing.T) {
+	i := IO.NewInterrupts()
+	via := i.Add("VIA", false)
+	acia := i.Add("ACIA", false)
+
+	i.Set(via, true)
+	i.Set(acia, true)
+	i.Set(via, false)
+	if !i.IRQ() {
+		t.Fatal("IRQ released with the ACIA still holding it")
+	}
+	if a := i.Asserting(); len(a) != 1 || a[0].Name != "ACIA" {
+		t.Errorf("asserting %v, want the ACIA", a)
+	}
+	i.Set(acia, false)
+	if i.IRQ() {
+		t.Fatal("IRQ asserted with nothing holding it")
+	}
+	if i.NMI() {
+		t.Error("an IRQ source pulled NMI")
+	}
+}
+
+func TestNMIEdge(t *testing.T) {
+	i := IO.NewInterrupts()
+	a := i.Add("A", true)
+	b := i.Add("B", true)
+
+	i.Set(a, true)
+	if !i.NMI() || i.NMI() {
+		t.Fatal("want one NMI for a falling edge")
+	}
+	i.Set(b, true) // the line's already low, no edge
+	i.Set(a, false)
+	if i.NMI() {
+		t.Error("NMI without an edge")
+	}
+	i.Set(b, false)
+	i.Set(a, true)
+	if !i.NMI() {
+		t.Error("no NMI for the second edge")
+	}
+	if i.IRQ() {
+		t.Error("an NMI source pulled IRQ")
+	}
+}
+
+func TestFired(t *testing.T) {
+	i := IO.NewInterrupts()
+	id := i.Add("VIA", false)
+	var fired []string
+	i.Fired = func(s *IO.Source) {
+		fired = append(fired, s.Name)
+	}
+	for _, level := range []bool{true, true, false, true} {
+		i.Set(id, level)
+	}
+	if len(fired) != 2 {
+		t.Errorf("fired %v times, want 2", len(fired))
+	}
+	if s := i.Find("VIA"); s == nil || s.Count != 2 || !s.Asserted {
+		t.Errorf("found %+v, want VIA asserted twice", s)
+	}
+	if i.Find("PIA") != nil {
+		t.Error("found a source that was never added")
+	}
+}
@@ -0,0 +1,27 @@
+package IO
+
+import "testing"
+
+func TestPageTable(t *testing.T) {
+	io := New([]*Device{
+		{Name: "RAM", Size: 0x7FFF, Offset: 0x0000},
+		(&Device{Name: "PIA", Size: 0x0003, Offset: 0xD010}).Decode(0xD010, 0xFF10, 0x0003),
+		{Name: "ROM", Size: 0x00FF, Offset: 0xFF00},
+	})
+	for _, c := range []struct {
+		page   int
+		shared bool
+	}{
+		{0x00, false},
+		{0x80, false}, // unmapped
+		{0xD0, true},  // the PIA's mirrors
+		{0xFF, false},
+	} {
+		if shared := io.pages[c.page].sub != nil; shared != c.shared {
+			t.Errorf("page $%02x: shared = %v, expected %v", c.page, shared, c.shared)
+		}
+	}
+	if _, _, err := io.getDevice(0x8000); err == nil {
+		t.Errorf("$8000 decoded")
+	}
+}
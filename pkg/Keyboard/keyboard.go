@@ -1,80 +1,71 @@
 package Keyboard
 
-import "fmt"
+import (
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/PIA"
+)
+
+var logger = Log.For("keyboard")
+
+/*
+	The Apple-1 keyboard, on port A of the PIA.  B1-B7 drive PA0-PA6, PA7
+	is tied high, and the strobe goes to CA1, so every key sets CRA b7
+	until the CPU reads the port.
+
+	Keys typed faster than the CPU reads them are queued, the next one is
+	strobed as soon as the last one has been read.
+*/
 
 type Keyboard struct {
 	buffer []byte
-	offset uint16
-	mode   uint8
+	key    byte // on the data lines
+	port   *PIA.Port
 }
 
-func New(offset uint16) *Keyboard {
+func New() *Keyboard {
 	return &Keyboard{
 		buffer: make([]byte, 0),
-		offset: offset,
-		mode:   0x00,
 	}
 }
 
-// func (k *Keyboard) AppendKeys(keys []byte) {
-// 	for _, key := range keys {
-// 		k.buffer = append(k.buffer, key)
-// 	}
-// }
+// Connect wires the keyboard to a PIA port
+func (k *Keyboard) Connect(port *PIA.Port) {
+	k.port = port
+	port.Input = func() byte {
+		return 0x80 | k.key
+	}
+	port.Read = k.next
+}
 
 func (k *Keyboard) AppendKey(key byte) {
+	logger.Debugf("key $%02x", key)
 	k.buffer = append(k.buffer, key)
+	k.next()
 }
 
-// IO.Memory Interface
-func (k *Keyboard) Size() uint16 {
-	return 1
-}
-
-func (k *Keyboard) Set(addr uint16, value byte) error {
-	if addr == k.offset+1 {
-		fmt.Printf("keyboard setting control registers: $%04x $%02x\n", addr, value)
-		k.mode = value
-		return nil
-	}
-
-	if k.mode == 0x00 {
-		fmt.Printf("keyboard in configuration mode, ignoring: $%04x $%02x\n", addr, value)
-		return nil
+// next strobes the next key once the last one has been read, which is
+// when CRA b7 is clear (a reset clears it too, losing the key)
+func (k *Keyboard) next() {
+	if k.port == nil || k.port.CR&0x80 != 0 || len(k.buffer) == 0 {
+		return
 	}
-
-	return fmt.Errorf("Keyboard: not yet implemented")
-}
-func (k *Keyboard) SetWord(addr uint16, value uint16) error {
-	return fmt.Errorf("Keyboard: not yet implemented")
+	k.key = k.buffer[0] & 0x7F
+	k.buffer = k.buffer[1:]
+	logger.Debugf("strobe $%02x, %v left", k.key, len(k.buffer))
+	k.port.SetC1(true)
+	k.port.SetC1(false)
 }
 
-func (k *Keyboard) Get(addr uint16) (byte, error) {
-	if addr == k.offset+1 {
-		if len(k.buffer) > 0 {
-			fmt.Printf("Buffer: %v", k.buffer)
-			return 0x80, nil
-		} else {
-			return 0x00, nil
-		}
-	}
-
-	fmt.Printf("Buffer: %v", k.buffer)
-	if len(k.buffer) > 0 {
-		key := k.buffer[0]
-		k.buffer = k.buffer[1:]
-		fmt.Printf("Keyboard: %v\n", k.buffer)
-		// set bit 7 to 1
-		return 0x80 | key, nil
+// Translate maps a byte typed on the host to the key the Apple-1 expects,
+// upper case letters, CR for return and '_', the rub out, for backspace
+func Translate(b byte) byte {
+	switch {
+	case b == '\n':
+		return 0x0D
+	case b == 0x08 || b == 0x7F:
+		return '_'
+	case b >= 'a' && b <= 'z':
+		return b - ('a' - 'A')
 	}
-	return 0x00, nil
-
-	// return byte(key), fmt.Errorf("Keyboard: read-only, invalid memory access")
-}
-func (k *Keyboard) GetWord(addr uint16) (uint16, error) {
-	return 0xEAEA, fmt.Errorf("Keyboard: read-only, invalid memory access")
-}
-
-func (k *Keyboard) Load(bytes []byte) (uint16, error) {
-	return 0x00, fmt.Errorf("not implemented: %v", len(bytes))
+	return b
 }
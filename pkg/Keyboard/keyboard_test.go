@@ -0,0 +1,48 @@
+package Keyboard
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/PIA"
+)
+
+func TestTranslate(t *testing.T) {
+	for in, want := range map[byte]byte{
+		'\n': 0x0D,
+		'\r': 0x0D,
+		0x08: '_',
+		0x7F: '_',
+		'a':  'A',
+		'z':  'Z',
+		'A':  'A',
+		'1':  '1',
+	} {
+		if got := Translate(in); got != want {
+			t.Errorf("Translate($%02x) = $%02x, want $%02x", in, got, want)
+		}
+	}
+}
+
+// TestQueue types ahead of the CPU, each key is strobed once the last one
+// has been read from the port
+func TestQueue(t *testing.T) {
+	pia := PIA.New(0xD010)
+	k := New()
+	k.Connect(pia.A)
+	pia.Set(0xD011, 0xA7) // like Wozmon, CA1 rising sets CRA b7
+
+	k.AppendKey('H')
+	k.AppendKey('I')
+	for _, want := range []byte{'H', 'I'} {
+		cr, _ := pia.Get(0xD011)
+		if cr&0x80 == 0 {
+			t.Fatalf("no strobe for %q", want)
+		}
+		if v, _ := pia.Get(0xD010); v != 0x80|want {
+			t.Fatalf("read $%02x, want $%02x", v, 0x80|want)
+		}
+	}
+	if cr, _ := pia.Get(0xD011); cr&0x80 != 0 {
+		t.Fatal("strobe with the queue empty")
+	}
+}
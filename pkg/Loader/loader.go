@@ -0,0 +1,327 @@
+package Loader
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+	Loaders for the ways 6502 programs get passed around:
+
+	hex   Intel HEX, ":10030000A9008D..."
+	srec  Motorola S-records, "S1130300A900..."
+	woz   Wozmon dumps, "0300: A9 00 8D ..." as printed by the monitor
+	bin   raw bytes, the address has to be given
+
+	Every format but bin carries its own addresses, and may carry an entry
+	point (Intel HEX start records, S7-S9, or a Wozmon "0300R" line).
+*/
+
+type Format string
+
+const (
+	IntelHex Format = "hex"
+	SRecord  Format = "srec"
+	Wozmon   Format = "woz"
+	Binary   Format = "bin"
+)
+
+// Segment is a run of bytes loaded from Addr up
+type Segment struct {
+	Addr uint16
+	Data []byte
+}
+
+type Image struct {
+	Segments []Segment
+	Entry    *uint16 // where to start, if the file says
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case IntelHex, SRecord, Wozmon, Binary:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected hex, srec, woz or bin", s)
+}
+
+// Detect guesses the format from the file extension, anything it doesn't
+// know is a raw binary
+func Detect(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihx", ".ihex":
+		return IntelHex
+	case ".s19", ".s28", ".s37", ".srec", ".mot":
+		return SRecord
+	case ".txt", ".woz", ".mon":
+		return Wozmon
+	}
+	return Binary
+}
+
+// Load reads path in format, or the detected format if it's "".  addr is
+// only used by bin.
+func Load(path string, format Format, addr uint16) (*Image, error) {
+	if len(format) == 0 {
+		format = Detect(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var img *Image
+	switch format {
+	case IntelHex:
+		img, err = ParseIntelHex(f)
+	case SRecord:
+		img, err = ParseSRecord(f)
+	case Wozmon:
+		img, err = ParseWozmon(f)
+	case Binary:
+		var data []byte
+		if data, err = io.ReadAll(f); err == nil {
+			img, err = Raw(data, addr)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return img, nil
+}
+
+func Raw(data []byte, addr uint16) (*Image, error) {
+	img := &Image{}
+	if err := img.add(int(addr), data); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Size is the number of bytes in all segments
+func (img *Image) Size() int {
+	n := 0
+	for _, s := range img.Segments {
+		n += len(s.Data)
+	}
+	return n
+}
+
+// add appends data at addr, joining it to the last segment when it follows
+// straight on
+func (img *Image) add(addr int, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if addr < 0 || addr+len(data) > 0x10000 {
+		return fmt.Errorf("%v bytes at $%x doesn't fit in 64K", len(data), addr)
+	}
+	if n := len(img.Segments); n > 0 {
+		last := &img.Segments[n-1]
+		if int(last.Addr)+len(last.Data) == addr {
+			last.Data = append(last.Data, data...)
+			return nil
+		}
+	}
+	img.Segments = append(img.Segments, Segment{
+		Addr: uint16(addr),
+		Data: append([]byte{}, data...),
+	})
+	return nil
+}
+
+func (img *Image) entry(addr int) error {
+	if addr < 0 || addr > 0xFFFF {
+		return fmt.Errorf("entry point $%x doesn't fit in 64K", addr)
+	}
+	e := uint16(addr)
+	img.Entry = &e
+	return nil
+}
+
+// lines calls f for every non blank line, with its line number for errors
+func lines(r io.Reader, f func(n int, line string) error) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if err := f(n, line); err != nil {
+			return fmt.Errorf("line %v: %v", n, err)
+		}
+	}
+	return s.Err()
+}
+
+// record decodes the hex digits of an Intel HEX or S-record line and checks
+// its checksum, ones complement for S-records and twos complement for HEX
+func record(digits string, twos bool) ([]byte, error) {
+	b, err := hex.DecodeString(digits)
+	if err != nil || len(b) < 2 {
+		return nil, fmt.Errorf("invalid record %q", digits)
+	}
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	if twos && sum != 0 || !twos && sum != 0xFF {
+		return nil, fmt.Errorf("bad checksum")
+	}
+	return b[:len(b)-1], nil
+}
+
+func ParseIntelHex(r io.Reader) (*Image, error) {
+	img := &Image{}
+	base := 0
+	done := false
+	err := lines(r, func(n int, line string) error {
+		if done {
+			return fmt.Errorf("data after the end of file record")
+		}
+		if line[0] != ':' {
+			return fmt.Errorf("expected ':'")
+		}
+		b, err := record(line[1:], true)
+		if err != nil {
+			return err
+		}
+		if len(b) < 4 || int(b[0]) != len(b)-4 {
+			return fmt.Errorf("bad record length")
+		}
+		addr := int(b[1])<<8 | int(b[2])
+		data := b[4:]
+		switch b[3] {
+		case 0x00: // data
+			return img.add(base+addr, data)
+		case 0x01: // end of file
+			done = true
+		case 0x02: // extended segment address
+			if len(data) != 2 {
+				return fmt.Errorf("bad extended segment address")
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 4
+		case 0x04: // extended linear address
+			if len(data) != 2 {
+				return fmt.Errorf("bad extended linear address")
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 16
+		case 0x03: // start segment address, CS:IP
+			if len(data) != 4 {
+				return fmt.Errorf("bad start segment address")
+			}
+			return img.entry((int(data[0])<<8|int(data[1]))<<4 + (int(data[2])<<8 | int(data[3])))
+		case 0x05: // start linear address
+			if len(data) != 4 {
+				return fmt.Errorf("bad start linear address")
+			}
+			return img.entry(int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3]))
+		default:
+			return fmt.Errorf("unknown record type %02x", b[3])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func ParseSRecord(r io.Reader) (*Image, error) {
+	img := &Image{}
+	err := lines(r, func(n int, line string) error {
+		if len(line) < 2 || line[0] != 'S' {
+			return fmt.Errorf("expected 'S'")
+		}
+		b, err := record(line[2:], false)
+		if err != nil {
+			return err
+		}
+		if int(b[0]) != len(b) {
+			return fmt.Errorf("bad record length")
+		}
+		b = b[1:]
+		// the address size of each record type
+		size := map[byte]int{'0': 2, '1': 2, '2': 3, '3': 4, '5': 2, '6': 3, '7': 4, '8': 3, '9': 2}[line[1]]
+		if size == 0 {
+			return fmt.Errorf("unknown record type S%c", line[1])
+		}
+		if len(b) < size {
+			return fmt.Errorf("record too short")
+		}
+		addr := 0
+		for _, v := range b[:size] {
+			addr = addr<<8 | int(v)
+		}
+		switch line[1] {
+		case '1', '2', '3':
+			return img.add(addr, b[size:])
+		case '7', '8', '9':
+			return img.entry(addr)
+		}
+		// S0 header, S5 and S6 counts
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// ParseWozmon reads monitor dumps and the lines typed to store them.  A
+// line is "ADDR: BB BB ..." or ": BB BB ..." to carry on where the last one
+// stopped, a lone "ADDRR" is the entry point.
+func ParseWozmon(r io.Reader) (*Image, error) {
+	img := &Image{}
+	next := -1
+	err := lines(r, func(n int, line string) error {
+		head, rest, found := strings.Cut(line, ":")
+		if !found {
+			run := strings.ToUpper(line)
+			if !strings.HasSuffix(run, "R") {
+				return fmt.Errorf("expected \"ADDR: BB ...\" or \"ADDRR\"")
+			}
+			addr, err := strconv.ParseUint(strings.TrimSuffix(run, "R"), 16, 16)
+			if err != nil {
+				return fmt.Errorf("invalid run address %q", line)
+			}
+			return img.entry(int(addr))
+		}
+
+		addr := next
+		if head = strings.TrimSpace(head); len(head) > 0 {
+			a, err := strconv.ParseUint(head, 16, 16)
+			if err != nil {
+				return fmt.Errorf("invalid address %q", head)
+			}
+			addr = int(a)
+		}
+		if addr < 0 {
+			return fmt.Errorf("no address to carry on from")
+		}
+
+		data := make([]byte, 0, 8)
+		for _, f := range strings.Fields(rest) {
+			v, err := strconv.ParseUint(f, 16, 8)
+			if err != nil {
+				return fmt.Errorf("invalid byte %q", f)
+			}
+			data = append(data, byte(v))
+		}
+		next = addr + len(data)
+		return img.add(addr, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
@@ -0,0 +1,100 @@
+package Loader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// program is LDA #$00 / STA $D012 at $0300, which every test file holds
+var program = []byte{0xA9, 0x00, 0x8D, 0x12, 0xD0}
+
+func check(t *testing.T, img *Image, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Segments) != 1 {
+		t.Fatalf("%v segments, want the records joined into 1", len(img.Segments))
+	}
+	if s := img.Segments[0]; s.Addr != 0x0300 || !bytes.Equal(s.Data, program) {
+		t.Fatalf("loaded % X at $%04x, want % X at $0300", s.Data, s.Addr, program)
+	}
+	if img.Entry == nil || *img.Entry != 0x0300 {
+		t.Fatalf("entry %v, want $0300", img.Entry)
+	}
+}
+
+func TestIntelHex(t *testing.T) {
+	img, err := ParseIntelHex(strings.NewReader(`
+		:03030000A9008DC4
+		:0203030012D016
+		:0400000500000300F4
+		:00000001FF
+	`))
+	check(t, img, err)
+}
+
+func TestSRecord(t *testing.T) {
+	img, err := ParseSRecord(strings.NewReader(`
+		S00600004844521B
+		S1060300A9008DC0
+		S105030312D012
+		S9030300F9
+	`))
+	check(t, img, err)
+}
+
+func TestWozmon(t *testing.T) {
+	img, err := ParseWozmon(strings.NewReader(`
+		0300: A9 00 8D
+		: 12 D0
+		300R
+	`))
+	check(t, img, err)
+}
+
+func TestErrors(t *testing.T) {
+	for _, c := range []struct {
+		format Format
+		text   string
+		err    string
+	}{
+		{IntelHex, ":03030000A9008DC5", "bad checksum"},
+		{IntelHex, ":00000001FF\n:00000001FF", "after the end of file"},
+		{IntelHex, "03030000A9008DC4", "expected ':'"},
+		{SRecord, "S1060300A9008DC1", "bad checksum"},
+		{SRecord, "S4030300F9", "unknown record type"},
+		{Wozmon, ": A9", "no address"},
+		{Wozmon, "0300: A9 0G", "invalid byte"},
+		{Wozmon, "FFFF: A9 00", "doesn't fit"},
+	} {
+		var err error
+		r := strings.NewReader(c.text)
+		switch c.format {
+		case IntelHex:
+			_, err = ParseIntelHex(r)
+		case SRecord:
+			_, err = ParseSRecord(r)
+		case Wozmon:
+			_, err = ParseWozmon(r)
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v %q: got %v, want %q", c.format, c.text, err, c.err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for path, want := range map[string]Format{
+		"basic.HEX":  IntelHex,
+		"basic.s19":  SRecord,
+		"wozmon.txt": Wozmon,
+		"basic.bin":  Binary,
+		"basic":      Binary,
+	} {
+		if f := Detect(path); f != want {
+			t.Errorf("%v detected as %v, want %v", path, f, want)
+		}
+	}
+}
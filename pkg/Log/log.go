@@ -0,0 +1,169 @@
+package Log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	Leveled logging for the emulator's subsystems, built on log/slog.
+
+	Every package logs under its own category (io, memory, keyboard, ...)
+	and every category is off until it's enabled, so logging costs next to
+	nothing on the hot paths unless somebody asked for it:
+
+	Log.Enable("io,keyboard=info")  // io at debug, keyboard at info
+	Log.Enable("all")               // everything at debug
+*/
+
+// Off is above every slog level, a category at Off logs nothing
+const Off = slog.Level(64)
+
+type Logger struct {
+	*slog.Logger
+	level *slog.LevelVar
+}
+
+var (
+	mutex  sync.Mutex
+	levels = make(map[string]*slog.LevelVar)
+	all    = Off // level for categories created after Enable("all")
+	output = io.Writer(os.Stderr)
+)
+
+// sink lets SetOutput redirect loggers that were created before it was called
+type sink struct{}
+
+func (sink) Write(p []byte) (int, error) {
+	mutex.Lock()
+	w := output
+	mutex.Unlock()
+	return w.Write(p)
+}
+
+func For(category string) *Logger {
+	lv := level(category)
+	h := slog.NewTextHandler(sink{}, &slog.HandlerOptions{Level: lv})
+	return &Logger{
+		Logger: slog.New(h).With("log", category),
+		level:  lv,
+	}
+}
+
+func level(category string) *slog.LevelVar {
+	mutex.Lock()
+	defer mutex.Unlock()
+	lv, ok := levels[category]
+	if !ok {
+		lv = &slog.LevelVar{}
+		lv.Set(all)
+		levels[category] = lv
+	}
+	return lv
+}
+
+func SetOutput(w io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	output = w
+}
+
+// Enable turns on a comma separated list of category[=level], a category
+// without a level logs at debug.  "all" enables every category.
+func Enable(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		name, lvl, found := strings.Cut(item, "=")
+		l := slog.LevelDebug
+		if found {
+			var err error
+			if l, err = parseLevel(lvl); err != nil {
+				return err
+			}
+		}
+
+		if name == "all" {
+			mutex.Lock()
+			all = l
+			for _, lv := range levels {
+				lv.Set(l)
+			}
+			mutex.Unlock()
+			continue
+		}
+
+		mutex.Lock()
+		_, known := levels[name]
+		mutex.Unlock()
+		if !known {
+			return fmt.Errorf("unknown log category %q, expected one of: all, %v", name, strings.Join(Categories(), ", "))
+		}
+		level(name).Set(l)
+	}
+	return nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "off":
+		return Off, nil
+	}
+	return Off, fmt.Errorf("unknown log level %q, expected debug, info, warn, error or off", s)
+}
+
+func Categories() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// On reports whether the logger would log at level, check it before doing
+// any expensive work to build a message
+func (l *Logger) On(level slog.Level) bool {
+	return l.level.Level() <= level
+}
+
+func (l *Logger) Debugf(format string, a ...any) {
+	l.logf(slog.LevelDebug, format, a...)
+}
+
+func (l *Logger) Infof(format string, a ...any) {
+	l.logf(slog.LevelInfo, format, a...)
+}
+
+func (l *Logger) Warnf(format string, a ...any) {
+	l.logf(slog.LevelWarn, format, a...)
+}
+
+func (l *Logger) Errorf(format string, a ...any) {
+	l.logf(slog.LevelError, format, a...)
+}
+
+func (l *Logger) logf(level slog.Level, format string, a ...any) {
+	if !l.On(level) {
+		return
+	}
+	l.Logger.Log(context.Background(), level, fmt.Sprintf(format, a...))
+}
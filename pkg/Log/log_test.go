@@ -0,0 +1,46 @@
+package Log
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestEnable(t *testing.T) {
+	var out bytes.Buffer
+	SetOutput(&out)
+	io := For("test-io")
+	kbd := For("test-keyboard")
+
+	io.Debugf("before")
+	if io.On(slog.LevelError) || out.Len() > 0 {
+		t.Fatal("a category logged before it was enabled")
+	}
+
+	if err := Enable("test-io, test-keyboard=warn"); err != nil {
+		t.Fatal(err)
+	}
+	io.Debugf("bus $%02x", 0xA9)
+	kbd.Infof("key")
+	kbd.Warnf("dropped")
+	s := out.String()
+	if !strings.Contains(s, "bus $a9") || !strings.Contains(s, "log=test-io") {
+		t.Errorf("no debug line for test-io in %q", s)
+	}
+	if strings.Contains(s, "msg=key") || !strings.Contains(s, "dropped") {
+		t.Errorf("test-keyboard at warn logged %q", s)
+	}
+
+	Enable("all=off")
+	later := For("test-later")
+	if io.On(slog.LevelError) || later.On(slog.LevelError) {
+		t.Error("logging with everything off")
+	}
+
+	for _, spec := range []string{"nope", "test-io=loud"} {
+		if err := Enable(spec); err == nil {
+			t.Errorf("enabled %q", spec)
+		}
+	}
+}
@@ -0,0 +1,625 @@
+package Machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zoul0813/go6502/pkg/ACI"
+	"github.com/zoul0813/go6502/pkg/ACIA"
+	"github.com/zoul0813/go6502/pkg/Bank"
+	"github.com/zoul0813/go6502/pkg/Display"
+	"github.com/zoul0813/go6502/pkg/IO"
+	"github.com/zoul0813/go6502/pkg/Keyboard"
+	"github.com/zoul0813/go6502/pkg/Loader"
+	"github.com/zoul0813/go6502/pkg/Log"
+	"github.com/zoul0813/go6502/pkg/Memory"
+	"github.com/zoul0813/go6502/pkg/PIA"
+	"github.com/zoul0813/go6502/pkg/Serial"
+	"github.com/zoul0813/go6502/pkg/VIA"
+)
+
+var logger = Log.For("machine")
+
+/*
+	A machine is described by a JSON file, see machines/apple1.json:
+
+	{
+		"name": "Apple-1",
+		"cpu": { "variant": "6502", "clock": 1000 },
+		"memory": [
+			{ "name": "RAM", "type": "ram", "start": "$0000", "size": "$8000" },
+			{ "name": "ROM", "type": "rom", "start": "$F000", "size": "$1000",
+			  "file": "rom/rom.bin",
+			  "decode": [{ "match": "$E000", "mask": "$F000", "lines": "$0FFF" }] }
+		],
+		"devices": [
+			{ "name": "PIA", "type": "pia", "address": "$D010",
+			  "port-a": "keyboard", "port-b": "display" }
+		]
+	}
+
+	A "bank" region holds several banks of RAM or ROM behind one window, a
+	"bank-select" device is the latch that switches them:
+
+	{ "name": "HIMEM", "type": "bank", "start": "$8000", "size": "$4000",
+	  "banks": [{ "type": "ram" }, { "type": "rom", "file": "rom/basic.bin" }] }
+	{ "name": "Latch", "type": "bank-select", "address": "$C000",
+	  "banks": ["HIMEM"], "mask": "$01" }
+
+	A "via" is a 6522, its IRQ goes to the CPU's IRQ line:
+
+	{ "name": "VIA", "type": "via", "address": "$6000" }
+
+	With "tx" and "rx" its pins are a software UART's, see Serial.BitBang.
+	TX is CA2, CB2 or a port bit, RX any of CA1, CA2, CB1, CB2 and port
+	bits joined by "+", they're all driven together:
+
+	{ "name": "VIA", "type": "via", "address": "$6000", "serial": "pty",
+	  "tx": "CA2", "rx": "CA1+PA0", "baud": 1200, "format": "8N1" }
+
+	A "6551" is an ACIA, "serial" says where its other end is, see
+	Serial.Open, and "tx-bug" makes it a W65C51N:
+
+	{ "name": "ACIA", "type": "6551", "address": "$5000", "serial": "pty" }
+
+	A "6850" is the Motorola ACIA, "baud-clock" is what's on its TX and RX
+	clock pins in Hz, 1.8432MHz if it's not given.
+
+	"irq" is where a device's interrupt output goes, "irq" (the default),
+	"nmi" or "none".  The PIAs' aren't wired unless it's given, like on the
+	Apple-1, and then IRQA and IRQB both go to the one line.
+
+	A display's "char-rate" is how many characters a second it takes, PB7
+	stays busy in between like the Apple-1's shift registers, 0 (the
+	default) takes them straight away.
+
+	Addresses are hex strings ("$D010", "0xD010" or "D010") or plain
+	numbers, and so are sizes, which are in bytes.  Devices are mapped in
+	the order they're listed, memory first, and the first one wins where
+	they overlap.  Files are read relative to the working directory.
+*/
+
+type Config struct {
+	Name     string   `json:"name"`
+	CPU      CPU      `json:"cpu"`
+	Unmapped string   `json:"unmapped"` // openbus, fixed:XX or fault, see IO.Unmapped
+	Memory   []Region `json:"memory"`
+	Devices  []Device `json:"devices"`
+}
+
+type CPU struct {
+	Variant string `json:"variant"`
+	Clock   int    `json:"clock"` // kHz, 0 leaves it to the -clock flag
+	Reset   *Addr  `json:"reset"` // start here instead of at the reset vector
+}
+
+type Region struct {
+	Name   string       `json:"name"`
+	Type   string       `json:"type"` // ram, rom or bank
+	Start  Addr         `json:"start"`
+	Size   Size         `json:"size"` // bytes
+	File   string       `json:"file"` // raw at Start, or any format the Loader knows
+	Fill   string       `json:"fill"` // ram at power on, see Memory.Fill
+	Banks  []BankConfig `json:"banks"`
+	Decode []Rule       `json:"decode"`
+}
+
+// BankConfig is one bank of a bank region, it has the region's Start and Size
+type BankConfig struct {
+	Type string `json:"type"` // ram or rom
+	File string `json:"file"`
+}
+
+type Device struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Address Addr     `json:"address"`
+	Decode  []Rule   `json:"decode"`
+	PortA   string   `json:"port-a"` // pia, keyboard or display
+	PortB   string   `json:"port-b"`
+	Cols    int      `json:"cols"`   // display
+	Rows    int      `json:"rows"`   // display
+	Banks   []string `json:"banks"`  // bank-select, the bank regions it switches
+	Mask    Addr     `json:"mask"`   // bank-select, bits of the latch used ($FF if 0)
+	File    string   `json:"file"`   // aci, the PROM at address + $100
+	Serial  string   `json:"serial"` // uarts, pty, stdio or tcp:ADDR
+	TX      string   `json:"tx"`     // via, the software UART's pins
+	RX      string   `json:"rx"`
+	Baud    int      `json:"baud"`       // via, 1200 if 0
+	Format  string   `json:"format"`     // via, 8N1 if ""
+	TxBug   bool     `json:"tx-bug"`     // 6551, the W65C51N's stuck TDRE
+	Clock   int      `json:"baud-clock"` // 6850, Hz
+	Rate    float64  `json:"char-rate"`  // display, characters a second
+	IRQ     string   `json:"irq"`        // irq, nmi or none, irq if ""
+}
+
+// Rule is an extra decode rule, see IO.Rule
+type Rule struct {
+	Match Addr `json:"match"`
+	Mask  Addr `json:"mask"`
+	Lines Addr `json:"lines"`
+}
+
+type Addr uint16
+
+func (a *Addr) UnmarshalJSON(data []byte) error {
+	var n uint16
+	if err := json.Unmarshal(data, &n); err == nil {
+		*a = Addr(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("address %s is neither a number nor a string", data)
+	}
+	v, err := ParseAddr(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Size is a region's length in bytes, a number or a hex string like an
+// Addr, up to $10000
+type Size int
+
+func (z *Size) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("size %s is neither a number nor a string", data)
+		}
+		h := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "$"), "0x")
+		v, err := strconv.ParseUint(h, 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid size %q", s)
+		}
+		n = int(v)
+	}
+	if n < 1 || n > 0x10000 {
+		return fmt.Errorf("size %s is outside 1-$10000", data)
+	}
+	*z = Size(n)
+	return nil
+}
+
+// ParseAddr reads a hex address, with or without a $ or 0x prefix
+func ParseAddr(s string) (Addr, error) {
+	h := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "$"), "0x")
+	v, err := strconv.ParseUint(h, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return Addr(v), nil
+}
+
+// Machine is a built Config, the devices the front ends need are pulled out
+type Machine struct {
+	Config     *Config
+	IO         *IO.IO
+	Devices    []*IO.Device
+	Memory     map[string]*Memory.Memory
+	Banks      map[string]*Bank.Bank
+	Keyboard   *Keyboard.Keyboard
+	Display    *Display.Display
+	ACI        *ACI.ACI
+	Clock      *IO.Clock
+	Interrupts *IO.Interrupts
+	UARTs      []UART
+}
+
+// UART is a serial device and where its host end goes, see Serial.Open
+type UART struct {
+	Name   string
+	Serial string
+	Chip   Serial.Device
+}
+
+// SetClock sets how fast the CPU runs, in Hz
+func (m *Machine) SetClock(hz float64) {
+	m.Clock.Hz = hz
+}
+
+// Reset pulls /RESET on the devices that have one, the CPU is reset
+// separately
+func (m *Machine) Reset() {
+	for _, d := range m.Devices {
+		if r, ok := d.Chip.(interface{ Reset() }); ok {
+			r.Reset()
+		}
+	}
+}
+
+// Tick moves the machine's Clock on by the cycles the CPU has run
+func (m *Machine) Tick(cycles uint64) {
+	m.Clock.Advance(cycles)
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return c, nil
+}
+
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	switch c.CPU.Variant {
+	case "", "6502":
+	default:
+		return nil, fmt.Errorf("unsupported cpu variant %q", c.CPU.Variant)
+	}
+	if _, err := IO.ParseUnmapped(c.Unmapped); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) Build() (*Machine, error) {
+	m := &Machine{
+		Config:     c,
+		Devices:    make([]*IO.Device, 0),
+		Memory:     make(map[string]*Memory.Memory),
+		Banks:      make(map[string]*Bank.Bank),
+		Clock:      IO.NewClock(1_000_000),
+		Interrupts: IO.NewInterrupts(),
+	}
+
+	for _, r := range c.Memory {
+		if r.Type != "bank" {
+			mem, err := newMemory(r.Name, r.Type, r.Start, r.Size, r.File, r.Fill)
+			if err != nil {
+				return nil, err
+			}
+			m.Memory[r.Name] = mem
+			m.add(r.Name, mem, r.Start, r.Decode)
+			continue
+		}
+
+		if len(r.Banks) == 0 {
+			return nil, fmt.Errorf("%v: a bank region needs at least one bank", r.Name)
+		}
+		b := Bank.New(r.Name, uint16(r.Start))
+		for i, bc := range r.Banks {
+			mem, err := newMemory(fmt.Sprintf("%v bank %v", r.Name, i), bc.Type, r.Start, r.Size, bc.File, r.Fill)
+			if err != nil {
+				return nil, err
+			}
+			b.Add(mem)
+		}
+		m.Banks[r.Name] = b
+		m.add(r.Name, b, r.Start, r.Decode)
+	}
+
+	for _, d := range c.Devices {
+		var chip IO.Memory
+		switch d.Type {
+		case "pia":
+			pia := PIA.New(uint16(d.Address))
+			for _, wire := range []struct {
+				name string
+				port *PIA.Port
+			}{{d.PortA, pia.A}, {d.PortB, pia.B}} {
+				switch wire.name {
+				case "":
+				case "keyboard":
+					if m.Keyboard != nil {
+						return nil, fmt.Errorf("%v: only one keyboard is supported", d.Name)
+					}
+					m.Keyboard = Keyboard.New()
+					m.Keyboard.Connect(wire.port)
+				case "display":
+					if m.Display != nil {
+						return nil, fmt.Errorf("%v: only one display is supported", d.Name)
+					}
+					cols, rows := d.Cols, d.Rows
+					if cols == 0 {
+						cols = 40
+					}
+					if rows == 0 {
+						rows = 24
+					}
+					m.Display = Display.New(cols, rows, m.Clock)
+					m.Display.Rate = d.Rate
+					m.Display.Connect(wire.port)
+				default:
+					return nil, fmt.Errorf("%v: unknown peripheral %q on port %v, expected keyboard or display", d.Name, wire.name, wire.port.Name)
+				}
+			}
+			chip = pia
+		case "keyboard", "display":
+			return nil, fmt.Errorf("%v: the %v is on a PIA now, use a pia device with \"port-a\": \"keyboard\" and \"port-b\": \"display\"", d.Name, d.Type)
+		case "via":
+			via := VIA.New(uint16(d.Address))
+			if len(d.TX) > 0 || len(d.RX) > 0 {
+				line, err := bitBang(d, via, m.Clock)
+				if err != nil {
+					return nil, fmt.Errorf("%v: %v", d.Name, err)
+				}
+				m.UARTs = append(m.UARTs, UART{Name: d.Name, Serial: d.Serial, Chip: line})
+			}
+			chip = via
+		case "6551":
+			acia := ACIA.New6551(uint16(d.Address), m.Clock)
+			acia.TxBug = d.TxBug
+			m.UARTs = append(m.UARTs, UART{Name: d.Name, Serial: d.Serial, Chip: acia})
+			chip = acia
+		case "6850":
+			acia := ACIA.New6850(uint16(d.Address), m.Clock)
+			if d.Clock > 0 {
+				acia.BaudClock = float64(d.Clock)
+			}
+			m.UARTs = append(m.UARTs, UART{Name: d.Name, Serial: d.Serial, Chip: acia})
+			chip = acia
+		case "bank-select":
+			banks := make([]*Bank.Bank, 0, len(d.Banks))
+			for _, name := range d.Banks {
+				b, ok := m.Banks[name]
+				if !ok {
+					return nil, fmt.Errorf("%v: no bank region named %q", d.Name, name)
+				}
+				banks = append(banks, b)
+			}
+			latch := Bank.NewSelect(uint16(d.Address), banks...)
+			if d.Mask != 0 {
+				latch.Mask = byte(d.Mask)
+			}
+			chip = latch
+		case "aci":
+			if m.ACI != nil {
+				return nil, fmt.Errorf("%v: only one ACI is supported", d.Name)
+			}
+			rom := make([]byte, 0x100)
+			if len(d.File) > 0 {
+				base := uint16(d.Address) + 0x100
+				img, err := loadImage(d.File, base, len(rom))
+				if err != nil {
+					return nil, fmt.Errorf("%v: %v", d.Name, err)
+				}
+				for _, s := range img.Segments {
+					copy(rom[s.Addr-base:], s.Data)
+				}
+			}
+			m.ACI = ACI.New(uint16(d.Address), rom, m.Clock)
+			chip = m.ACI
+		default:
+			return nil, fmt.Errorf("%v: unknown device type %q", d.Name, d.Type)
+		}
+		if t, ok := chip.(IO.Ticker); ok {
+			m.Clock.Add(t)
+		}
+		if err := m.wire(d, chip); err != nil {
+			return nil, err
+		}
+		m.add(d.Name, chip, d.Address, d.Decode)
+	}
+
+	m.IO = IO.New(m.Devices)
+	m.IO.Unmapped, _ = IO.ParseUnmapped(c.Unmapped)
+	return m, nil
+}
+
+// wire connects a device's interrupt output to IRQ or NMI, the chip sets
+// its source whenever the output changes
+func (m *Machine) wire(d Device, chip IO.Memory) error {
+	var outs []*func(asserted bool)
+	var level func() bool
+	switch c := chip.(type) {
+	case *PIA.PIA:
+		// IRQA and IRQB share a source, and like on the Apple-1 they're
+		// left alone unless "irq" asks for them
+		if len(d.IRQ) == 0 {
+			return nil
+		}
+		outs = []*func(bool){&c.A.IRQ, &c.B.IRQ}
+		level = func() bool {
+			return c.A.Asserted() || c.B.Asserted()
+		}
+	case *VIA.VIA:
+		outs, level = []*func(bool){&c.IRQ}, c.Asserted
+	case *ACIA.MOS6551:
+		outs, level = []*func(bool){&c.IRQ}, c.Asserted
+	case *ACIA.MC6850:
+		outs, level = []*func(bool){&c.IRQ}, c.Asserted
+	}
+	switch d.IRQ {
+	case "", "irq", "nmi":
+		if len(outs) == 0 {
+			if len(d.IRQ) > 0 {
+				return fmt.Errorf("%v: a %v has no interrupt output", d.Name, d.Type)
+			}
+			return nil
+		}
+		id := m.Interrupts.Add(d.Name, d.IRQ == "nmi")
+		for _, out := range outs {
+			*out = func(bool) {
+				m.Interrupts.Set(id, level())
+			}
+		}
+	case "none":
+	default:
+		return fmt.Errorf("%v: invalid irq %q, expected irq, nmi or none", d.Name, d.IRQ)
+	}
+	return nil
+}
+
+// bitBang wires a software UART's line to the VIA's pins
+func bitBang(d Device, via *VIA.VIA, clock *IO.Clock) (*Serial.BitBang, error) {
+	line := Serial.NewBitBang(clock)
+	if d.Baud > 0 {
+		line.Baud = float64(d.Baud)
+	}
+	if len(d.Format) > 0 {
+		if err := line.SetFormat(d.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.TX) > 0 {
+		port, bit, err := viaPin(via, d.TX)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bit == 1:
+			return nil, fmt.Errorf("tx can't be %v, it's an input", d.TX)
+		case bit == 2:
+			port.C2 = line.SetTX
+		default:
+			mask := byte(1) << (bit - 10)
+			port.Output = func(value byte) {
+				// an input pin floats high
+				line.SetTX(value&mask != 0 || port.DDR&mask == 0)
+			}
+		}
+	}
+
+	var rx []func(level bool)
+	for _, name := range strings.Split(d.RX, "+") {
+		if len(name) == 0 {
+			continue
+		}
+		port, bit, err := viaPin(via, name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bit == 1:
+			rx = append(rx, port.SetC1)
+		case bit == 2:
+			rx = append(rx, port.SetC2)
+		default:
+			mask := byte(1) << (bit - 10)
+			input := port.Input
+			port.Input = func() byte {
+				v := byte(0xFF)
+				if input != nil {
+					v = input()
+				}
+				if !line.Level() {
+					v &^= mask
+				}
+				return v
+			}
+		}
+	}
+	line.RX = func(level bool) {
+		for _, f := range rx {
+			f(level)
+		}
+	}
+	// the line idles high
+	line.RX(true)
+	return line, nil
+}
+
+// viaPin finds a pin by name, bit is 1 or 2 for C1 and C2 and 10 plus the
+// bit for a port bit
+func viaPin(via *VIA.VIA, name string) (*VIA.Port, int, error) {
+	n := strings.ToUpper(strings.TrimSpace(name))
+	if len(n) == 3 {
+		var port *VIA.Port
+		switch n[:2] {
+		case "CA", "PA":
+			port = via.A
+		case "CB", "PB":
+			port = via.B
+		}
+		switch {
+		case port == nil:
+		case n[0] == 'C' && (n[2] == '1' || n[2] == '2'):
+			return port, int(n[2] - '0'), nil
+		case n[0] == 'P' && n[2] >= '0' && n[2] <= '7':
+			return port, 10 + int(n[2]-'0'), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("unknown VIA pin %q, expected CA1, CA2, CB1, CB2, PA0-PA7 or PB0-PB7", name)
+}
+
+func newMemory(name string, kind string, start Addr, size Size, file string, fill string) (*Memory.Memory, error) {
+	var readOnly bool
+	switch kind {
+	case "ram":
+	case "rom":
+		readOnly = true
+	default:
+		return nil, fmt.Errorf("%v: unknown memory type %q, expected ram, rom or bank", name, kind)
+	}
+	if int(start)+int(size) > 0x10000 {
+		return nil, fmt.Errorf("%v: $%04x bytes from $%04x run past $FFFF", name, int(size), start)
+	}
+	// Memory.New takes the last offset
+	mem := Memory.New(uint32(size)-1, uint16(start), readOnly)
+	if len(fill) > 0 && !readOnly {
+		if err := mem.Fill(fill); err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	if len(file) > 0 {
+		img, err := loadImage(file, uint16(start), int(size))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		for _, s := range img.Segments {
+			for i, b := range s.Data {
+				mem.Poke(s.Addr+uint16(i), b)
+			}
+		}
+	}
+	return mem, nil
+}
+
+// loadImage reads a file in any format the Loader knows, a raw file is
+// taken to start at start, and checks it fits in size bytes from there
+func loadImage(file string, start uint16, size int) (*Loader.Image, error) {
+	img, err := Loader.Load(file, "", start)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range img.Segments {
+		if int(s.Addr) < int(start) || int(s.Addr)+len(s.Data) > int(start)+size {
+			return nil, fmt.Errorf("%v: $%04x-$%04x is outside $%04x-$%04x", file, s.Addr, int(s.Addr)+len(s.Data)-1, start, int(start)+size-1)
+		}
+	}
+	logger.Infof("loading %v, %v bytes at $%04x", file, img.Size(), start)
+	return img, nil
+}
+
+// RAM lists every writable memory, the banks of bank regions included
+func (m *Machine) RAM() []*Memory.Memory {
+	ram := make([]*Memory.Memory, 0)
+	for _, r := range m.Config.Memory {
+		if mem, ok := m.Memory[r.Name]; ok && !mem.ReadOnly {
+			ram = append(ram, mem)
+		}
+		if b, ok := m.Banks[r.Name]; ok {
+			for i := 0; i < b.Banks(); i++ {
+				if !b.Bank(i).ReadOnly {
+					ram = append(ram, b.Bank(i))
+				}
+			}
+		}
+	}
+	return ram
+}
+
+func (m *Machine) add(name string, chip IO.Memory, offset Addr, rules []Rule) {
+	d := IO.NewDevice(name, chip, uint16(offset))
+	for _, r := range rules {
+		d.Decode(uint16(r.Match), uint16(r.Mask), uint16(r.Lines))
+	}
+	m.Devices = append(m.Devices, d)
+}
@@ -0,0 +1,105 @@
+package Machine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/PIA"
+)
+
+func build(t *testing.T, config string) (*Machine, error) {
+	t.Helper()
+	c, err := Parse([]byte(config))
+	if err != nil {
+		return nil, err
+	}
+	return c.Build()
+}
+
+func TestSizes(t *testing.T) {
+	m, err := build(t, `{
+		"memory": [
+			{ "name": "RAM", "type": "ram", "start": "$0000", "size": "$8000" },
+			{ "name": "TOP", "type": "ram", "start": "$F000", "size": 4096 }
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"RAM": 0x8000, "TOP": 0x1000} {
+		if n := len(m.Memory[name].Bytes); n != want {
+			t.Errorf("%v is $%04x bytes, want $%04x", name, n, want)
+		}
+	}
+	m.IO.Set(0x7FFF, 0x11)
+	m.IO.Set(0xFFFF, 0x22)
+	if v, _ := m.IO.Get(0x7FFF); v != 0x11 {
+		t.Errorf("$7FFF read $%02x, want $11", v)
+	}
+	if v, _ := m.IO.Get(0xFFFF); v != 0x22 {
+		t.Errorf("$FFFF read $%02x, want $22", v)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, c := range []struct {
+		config string
+		err    string
+	}{
+		{`{ "cpu": { "variant": "65816" } }`, "unsupported cpu variant"},
+		{`{ "memory": [{ "name": "RAM", "type": "ram", "size": 0 }] }`, "outside 1-$10000"},
+		{`{ "memory": [{ "name": "RAM", "type": "ram", "size": "$10001" }] }`, "outside 1-$10000"},
+		{`{ "memory": [{ "name": "RAM", "type": "ram", "size": "lots" }] }`, "invalid size"},
+		{`{ "memory": [{ "name": "RAM", "type": "ram", "start": "$F000", "size": "$2000" }] }`, "run past $FFFF"},
+		{`{ "memory": [{ "name": "RAM", "type": "flash", "size": 1 }] }`, "unknown memory type"},
+		{`{ "devices": [{ "name": "X", "type": "tape" }] }`, "unknown device type"},
+	} {
+		_, err := build(t, c.config)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: got %v, want %q", c.config, err, c.err)
+		}
+	}
+}
+
+func TestInterruptWiring(t *testing.T) {
+	m, err := build(t, `{
+		"devices": [
+			{ "name": "PIA", "type": "pia", "address": "$D010", "irq": "irq" },
+			{ "name": "KBD", "type": "pia", "address": "$D020" },
+			{ "name": "VIA", "type": "via", "address": "$6000", "irq": "nmi" }
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.Interrupts.Sources()); n != 2 {
+		t.Fatalf("%v interrupt sources, want the PIA and the VIA", n)
+	}
+
+	pia := m.Devices[0].Chip.(*PIA.PIA)
+	m.IO.Set(0xD013, 0x03) // CB1 rising, IRQ enabled
+	pia.B.SetC1(true)
+	if !m.Interrupts.IRQ() {
+		t.Error("IRQB didn't assert IRQ")
+	}
+	m.IO.Set(0xD013, 0x07)
+	m.IO.Get(0xD012)
+	if m.Interrupts.IRQ() {
+		t.Error("IRQ still asserted after reading port B")
+	}
+
+	m.IO.Set(0x600E, 0xC0) // T1 IRQ enabled
+	m.IO.Set(0x6004, 0x10)
+	m.IO.Set(0x6005, 0x00)
+	m.Tick(0x20)
+	if !m.Interrupts.NMI() {
+		t.Error("T1 didn't pull NMI")
+	}
+	if m.Interrupts.IRQ() {
+		t.Error("the VIA asserted IRQ")
+	}
+	m.Reset()
+	if len(m.Interrupts.Asserting()) != 0 {
+		t.Errorf("%v still asserting after a reset", m.Interrupts.Asserting()[0].Name)
+	}
+}
@@ -1,26 +1,44 @@
 package Memory
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/Log"
 )
 
+var logger = Log.For("memory")
+
 type Memory struct {
 	Bytes    []byte
 	Offset   uint16
 	ReadOnly bool
 	Next     uint16
+
+	// state of each byte when tracking uninitialized reads, see Track
+	state  []uint8
+	report func(addr uint16)
 }
 
+const (
+	unwritten uint8 = iota
+	written
+	reported
+)
+
 func New(size uint32, offset uint16, readOnly bool) *Memory {
-	fmt.Printf("Creating %v ($%04x) bytes at %v ($%04x) ", size, size, offset, offset)
 	o := &Memory{
 		Bytes:    make([]byte, size+1), // we add 1, cause 0x0000:0xFFFF is (0:65536)
 		Offset:   offset,
 		ReadOnly: readOnly,
 		Next:     0,
 	}
-	fmt.Printf(" -> %v (%04x) bytes\n", len(o.Bytes), len(o.Bytes))
+	logger.Infof("created %v ($%04x) bytes at $%04x", len(o.Bytes), len(o.Bytes), offset)
 	return o
 }
 
@@ -30,7 +48,10 @@ func (o *Memory) Size() uint16 {
 }
 func (o *Memory) Get(addr uint16) (byte, error) {
 	a := addr - o.Offset
-	if int(a) <= len(o.Bytes) {
+	if int(a) < len(o.Bytes) {
+		if o.state != nil {
+			o.check(a)
+		}
 		return o.Bytes[a], nil
 	}
 	return 0x00, fmt.Errorf("%04x is out of range of %04x", addr, len(o.Bytes))
@@ -41,7 +62,7 @@ func (o *Memory) Set(addr uint16, value byte) error {
 	// defer o.mutex.Unlock()
 
 	if o.ReadOnly {
-		fmt.Printf("attempt to write to ROM at %04x\n", addr)
+		logger.Warnf("attempt to write $%02x to ROM at $%04x", value, addr)
 		return fmt.Errorf("attempt to write to ROM at %04x", addr)
 	}
 
@@ -51,12 +72,19 @@ func (o *Memory) Set(addr uint16, value byte) error {
 		return fmt.Errorf("%04x is out of range of %04x, trying to set %02x", addr, len(o.Bytes), value)
 	}
 	o.Bytes[a] = value
+	if o.state != nil {
+		o.state[a] = written
+	}
 	return nil
 }
 
 func (o *Memory) GetWord(addr uint16) (uint16, error) {
 	a := addr - o.Offset
-	if int(a+1) <= len(o.Bytes) {
+	if int(a)+1 < len(o.Bytes) {
+		if o.state != nil {
+			o.check(a)
+			o.check(a + 1)
+		}
 		lo := o.Bytes[a]
 		hi := o.Bytes[a+1]
 		var word uint16 = (uint16(hi) << 8) + uint16(lo)
@@ -85,6 +113,10 @@ func (o *Memory) SetWord(addr uint16, value uint16) error {
 	o.Bytes[a] = lo
 	// fmt.Printf(" | %02x = %02x\n", a+1, hi)
 	o.Bytes[a+1] = hi
+	if o.state != nil {
+		o.state[a] = written
+		o.state[a+1] = written
+	}
 	return nil
 }
 
@@ -95,17 +127,72 @@ func (o *Memory) Load(bytes []byte) (uint16, error) {
 		// fmt.Printf("%v: %04x: %02x (%v, %04x)\n", i, o.Offset+uint16(i), b, len(o.Bytes), len(bytes))
 		err := o.Set((o.Offset + uint16(i)), b)
 		if err != nil {
-			fmt.Printf("Load Error: %v\n", err)
 			log.Fatal(err)
 		}
 	}
 	o.ReadOnly = ro
-	fmt.Printf("Loaded %v bytes into %v\n", len(bytes), "memory")
+	logger.Infof("loaded %v bytes at $%04x", len(bytes), o.Offset)
 	return uint16(len(bytes)), nil
 }
 
 // Memory.Memory public
 
+// Peek is Get without side effects, it doesn't count as a read for Track
+func (o *Memory) Peek(addr uint16) (byte, error) {
+	a := addr - o.Offset
+	if int(a) < len(o.Bytes) {
+		return o.Bytes[a], nil
+	}
+	return 0x00, fmt.Errorf("%04x is out of range of %04x", addr, len(o.Bytes))
+}
+
+// Poke is Set for loaders, it writes ROM too
+func (o *Memory) Poke(addr uint16, value byte) error {
+	ro := o.ReadOnly
+	o.ReadOnly = false
+	err := o.Set(addr, value)
+	o.ReadOnly = ro
+	return err
+}
+
+// Track reports reads of bytes that nothing has written since power on,
+// report is called once for each of them.  Load counts as a write.
+func (o *Memory) Track(report func(addr uint16)) {
+	o.state = make([]uint8, len(o.Bytes))
+	o.report = report
+}
+
+// Fill sets every byte the way RAM might come up at power on: "zero",
+// "random" (or "random:seed" to repeat a run) or a repeating hex pattern
+// like "FF00"
+func (o *Memory) Fill(spec string) error {
+	name, seed, found := strings.Cut(strings.ToLower(spec), ":")
+	switch {
+	case name == "zero" && !found:
+		for i := range o.Bytes {
+			o.Bytes[i] = 0x00
+		}
+	case name == "random":
+		n := time.Now().UnixNano()
+		if found {
+			var err error
+			if n, err = strconv.ParseInt(seed, 10, 64); err != nil {
+				return fmt.Errorf("invalid random seed %q", seed)
+			}
+		}
+		rand.New(rand.NewSource(n)).Read(o.Bytes)
+	default:
+		pattern, err := hex.DecodeString(strings.TrimPrefix(spec, "$"))
+		if err != nil || len(pattern) == 0 {
+			return fmt.Errorf("invalid fill %q, expected zero, random[:seed] or a hex pattern", spec)
+		}
+		for i := range o.Bytes {
+			o.Bytes[i] = pattern[i%len(pattern)]
+		}
+	}
+	return nil
+}
+
 func (o *Memory) Goto(addr uint16) error {
 	a := addr - o.Offset
 	if int(a) > len(o.Bytes) {
@@ -132,6 +219,16 @@ func (o *Memory) WriteWord(value uint16) error {
 
 // Memory.Memory private
 
+func (o *Memory) check(a uint16) {
+	if o.state[a] != unwritten {
+		return
+	}
+	o.state[a] = reported
+	if o.report != nil {
+		o.report(o.Offset + a)
+	}
+}
+
 func (o *Memory) next(offset byte) error {
 	next := int(o.Next) + int(offset)
 	if next < len(o.Bytes) {
@@ -0,0 +1,56 @@
+package Memory
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrack(t *testing.T) {
+	m := New(0x00FF, 0x0200, false)
+	var reports []uint16
+	m.Track(func(addr uint16) {
+		reports = append(reports, addr)
+	})
+	m.Set(0x0210, 0x01)
+	m.Get(0x0210)
+	m.Get(0x0211)
+	m.Get(0x0211) // once per byte
+	m.Peek(0x0212)
+	m.GetWord(0x0213)
+	want := []uint16{0x0211, 0x0213, 0x0214}
+	if len(reports) != len(want) {
+		t.Fatalf("reported %04x, want %04x", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Fatalf("reported %04x, want %04x", reports, want)
+		}
+	}
+}
+
+func TestFill(t *testing.T) {
+	m := New(0x0003, 0x0000, false)
+	if err := m.Fill("$FF00"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Bytes, []byte{0xFF, 0x00, 0xFF, 0x00}) {
+		t.Errorf("pattern fill % X", m.Bytes)
+	}
+
+	m.Fill("random:42")
+	first := append([]byte{}, m.Bytes...)
+	m.Fill("zero")
+	if !bytes.Equal(m.Bytes, make([]byte, 4)) {
+		t.Errorf("zero fill % X", m.Bytes)
+	}
+	m.Fill("random:42")
+	if !bytes.Equal(m.Bytes, first) {
+		t.Errorf("seeded fills % X and % X differ", first, m.Bytes)
+	}
+
+	for _, spec := range []string{"", "zero:1", "random:x", "F"} {
+		if err := m.Fill(spec); err == nil {
+			t.Errorf("filled with %q", spec)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package PIA
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/Log"
+)
+
+var logger = Log.For("pia")
+
+/*
+	The Motorola 6821 Peripheral Interface Adapter, two 8 bit ports with
+	a pair of control lines each.
+
+	RS1 RS0  CR b2
+	 0   0    0     DDRA, 1 bits are outputs
+	 0   0    1     port A
+	 0   1          CRA
+	 1   0    0     DDRB
+	 1   0    1     port B
+	 1   1          CRB
+
+	Control registers:
+
+	b7     C1 active transition, read only, cleared by reading the port
+	b6     C2 active transition (C2 as an input), the same
+	b5-b3  C2 control
+	       0 e i  input, i enables its IRQ, e is the active edge (1 rising)
+	       1 0 0  handshake: A goes low on a read of the port, B on a
+	              write, and back high at the next C1 active transition
+	       1 0 1  pulse: low for a cycle after the read (A) or write (B)
+	       1 1 v  output, C2 is v
+	b2     0 selects the DDR, 1 the port
+	b1     C1 active edge, 1 rising
+	b0     C1 IRQ enable
+
+	IRQA and IRQB are asserted while a flag is set and its IRQ is enabled.
+	Peripherals are wired up through the Port callbacks, see Keyboard and
+	Display for the Apple-1's.
+*/
+
+type PIA struct {
+	A      *Port
+	B      *Port
+	offset uint16
+}
+
+type Port struct {
+	Name string
+
+	DDR byte
+	OR  byte
+	CR  byte
+
+	// peripheral wiring, any of these can be nil
+	Input  func() byte         // the pins, when the CPU reads the port
+	Output func(value byte)    // the output pins, when OR or DDR change
+	Read   func()              // the CPU has read the port, after the flags are cleared
+	C2     func(level bool)    // C2 as an output changed
+	IRQ    func(asserted bool) // the IRQ output changed
+
+	b   bool // port B handshakes on writes and reads its output pins from OR
+	c1  bool // input line levels
+	c2  bool
+	out bool // C2 as an output
+	irq bool
+}
+
+const (
+	irq1     = 0x80
+	irq2     = 0x40
+	c2Output = 0x20
+	orSelect = 0x04
+	c1Rising = 0x02
+	c1Enable = 0x01
+)
+
+func New(offset uint16) *PIA {
+	return &PIA{
+		A:      &Port{Name: "A", out: true},
+		B:      &Port{Name: "B", b: true, out: true},
+		offset: offset,
+	}
+}
+
+func (p *PIA) port(addr uint16) (*Port, bool) {
+	o := (addr - p.offset) & 0x03
+	if o < 2 {
+		return p.A, o == 1
+	}
+	return p.B, o == 3
+}
+
+// IO.Memory Interface
+func (p *PIA) Size() uint16 {
+	return 0x03
+}
+
+func (p *PIA) Get(addr uint16) (byte, error) {
+	port, cr := p.port(addr)
+	switch {
+	case cr:
+		return port.CR, nil
+	case port.CR&orSelect == 0:
+		return port.DDR, nil
+	}
+	v := port.pins()
+	port.CR &^= irq1 | irq2
+	port.update()
+	if !port.b {
+		port.handshake()
+	}
+	if port.Read != nil {
+		port.Read()
+	}
+	return v, nil
+}
+
+func (p *PIA) GetWord(addr uint16) (uint16, error) {
+	lo, _ := p.Get(addr)
+	hi, _ := p.Get(addr + 1)
+	return uint16(hi)<<8 | uint16(lo), nil
+}
+
+func (p *PIA) Set(addr uint16, value byte) error {
+	port, cr := p.port(addr)
+	switch {
+	case cr:
+		logger.Debugf("CR%v $%02x", port.Name, value)
+		port.CR = port.CR&(irq1|irq2) | value&^(irq1|irq2)
+		if port.CR&c2Output != 0 {
+			port.CR &^= irq2 // no C2 flags while it's an output
+		}
+		port.update()
+		port.control()
+	case port.CR&orSelect == 0:
+		logger.Debugf("DDR%v $%02x", port.Name, value)
+		port.DDR = value
+		port.output()
+	default:
+		port.OR = value
+		port.output()
+		if port.b {
+			port.handshake()
+		}
+	}
+	return nil
+}
+
+func (p *PIA) SetWord(addr uint16, value uint16) error {
+	p.Set(addr, byte(value))
+	return p.Set(addr+1, byte(value>>8))
+}
+
+func (p *PIA) Load(bytes []byte) (uint16, error) {
+	return 0x00, fmt.Errorf("PIA: can't load %v bytes into registers", len(bytes))
+}
+
+// Peek reads a register without clearing the flags
+func (p *PIA) Peek(addr uint16) (byte, error) {
+	port, cr := p.port(addr)
+	switch {
+	case cr:
+		return port.CR, nil
+	case port.CR&orSelect == 0:
+		return port.DDR, nil
+	}
+	return port.pins(), nil
+}
+
+// Reset clears the registers, like the /RESET pin
+func (p *PIA) Reset() {
+	for _, port := range []*Port{p.A, p.B} {
+		port.DDR = 0
+		port.OR = 0
+		port.CR = 0
+		port.update()
+		port.control()
+		port.output()
+	}
+}
+
+// SetC1 drives the C1 line, an active transition sets CR b7
+func (port *Port) SetC1(level bool) {
+	if level == port.c1 {
+		return
+	}
+	port.c1 = level
+	if level != (port.CR&c1Rising != 0) {
+		return
+	}
+	port.CR |= irq1
+	port.update()
+	// the end of a handshake
+	if port.CR&0x38 == 0x20 {
+		port.drive(true)
+	}
+}
+
+// SetC2 drives the C2 line, it's ignored while C2 is an output
+func (port *Port) SetC2(level bool) {
+	if level == port.c2 {
+		return
+	}
+	port.c2 = level
+	if port.CR&c2Output != 0 || level != (port.CR&0x10 != 0) {
+		return
+	}
+	port.CR |= irq2
+	port.update()
+}
+
+// C2Level is what's on C2, the output when it is one
+func (port *Port) C2Level() bool {
+	if port.CR&c2Output != 0 {
+		return port.out
+	}
+	return port.c2
+}
+
+// Asserted is the state of the port's IRQ output
+func (port *Port) Asserted() bool {
+	return port.irq
+}
+
+// pins is what a read of the port sees, port B reads its outputs from OR
+// and port A from the pins, which are the same unless they're overloaded
+func (port *Port) pins() byte {
+	in := byte(0xFF) // port A has pull ups
+	if port.b {
+		in = 0x00
+	}
+	if port.Input != nil {
+		in = port.Input()
+	}
+	return in&^port.DDR | port.OR&port.DDR
+}
+
+func (port *Port) output() {
+	if port.Output != nil {
+		port.Output(port.OR & port.DDR)
+	}
+}
+
+func (port *Port) update() {
+	irq := port.CR&irq1 != 0 && port.CR&c1Enable != 0 ||
+		port.CR&irq2 != 0 && port.CR&0x28 == 0x08
+	if irq != port.irq {
+		port.irq = irq
+		if port.IRQ != nil {
+			port.IRQ(irq)
+		}
+	}
+}
+
+// control sets C2 after a write to CR
+func (port *Port) control() {
+	switch port.CR & 0x38 {
+	case 0x30:
+		port.drive(false)
+	case 0x38:
+		port.drive(true)
+	case 0x20, 0x28:
+		// handshake and pulse idle high
+		port.drive(true)
+	}
+}
+
+// handshake pulls C2 low after the port is read (A) or written (B), pulse
+// mode brings it straight back up
+func (port *Port) handshake() {
+	switch port.CR & 0x38 {
+	case 0x20:
+		port.drive(false)
+	case 0x28:
+		port.drive(false)
+		port.drive(true)
+	}
+}
+
+func (port *Port) drive(level bool) {
+	if level == port.out {
+		return
+	}
+	port.out = level
+	if port.C2 != nil {
+		port.C2(level)
+	}
+}
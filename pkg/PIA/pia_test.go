@@ -0,0 +1,101 @@
+package PIA
+
+import "testing"
+
+func TestRegisters(t *testing.T) {
+	p := New(0xD010)
+	var out byte
+	p.B.Output = func(v byte) {
+		out = v
+	}
+	p.Set(0xD012, 0x7F) // DDRB, CRB b2 is clear
+	p.Set(0xD013, 0x04)
+	p.Set(0xD012, 0xC1)
+	if out != 0x41 {
+		t.Errorf("port B drove $%02x, want $41 through DDR $7F", out)
+	}
+	if v, _ := p.Get(0xD012); v != 0x41 {
+		t.Errorf("port B read $%02x, want $41", v)
+	}
+	p.Set(0xD013, 0x00)
+	if v, _ := p.Get(0xD012); v != 0x7F {
+		t.Errorf("DDRB read $%02x, want $7F", v)
+	}
+
+	// port A's pins, with pull ups where nothing drives them
+	p.A.Input = func() byte {
+		return 0x0F
+	}
+	p.Set(0xD011, 0x04)
+	if v, _ := p.Get(0xD010); v != 0x0F {
+		t.Errorf("port A read $%02x, want $0F", v)
+	}
+	p.A.Input = nil
+	if v, _ := p.Get(0xD010); v != 0xFF {
+		t.Errorf("port A read $%02x with nothing on it, want $FF", v)
+	}
+}
+
+func TestC1(t *testing.T) {
+	p := New(0xD010)
+	var irq []bool
+	p.A.IRQ = func(asserted bool) {
+		irq = append(irq, asserted)
+	}
+	p.Set(0xD011, 0x07) // rising edge, IRQ enabled
+
+	p.A.SetC1(true)
+	if v, _ := p.Peek(0xD011); v&0x80 == 0 {
+		t.Fatalf("CRA $%02x, want b7 set by C1 rising", v)
+	}
+	p.A.SetC1(false) // the wrong edge
+	p.Get(0xD010)
+	if v, _ := p.Peek(0xD011); v&0x80 != 0 {
+		t.Errorf("CRA $%02x, want b7 cleared by reading the port", v)
+	}
+	if len(irq) != 2 || !irq[0] || irq[1] {
+		t.Errorf("IRQA went %v, want [true false]", irq)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	p := New(0xD010)
+	var c2 []bool
+	p.A.C2 = func(level bool) {
+		c2 = append(c2, level)
+	}
+	p.Set(0xD011, 0x24) // CA2 handshake
+	p.Get(0xD010)
+	if p.A.C2Level() {
+		t.Error("CA2 still high after reading the port")
+	}
+	p.A.SetC1(true)
+	p.A.SetC1(false) // the active edge, falling
+	if !p.A.C2Level() {
+		t.Error("CA2 still low after CA1")
+	}
+
+	p.Set(0xD011, 0x2C) // pulse, a low blip on every read
+	c2 = nil
+	p.Get(0xD010)
+	if len(c2) != 2 || c2[0] || !c2[1] {
+		t.Errorf("CA2 went %v, want [false true]", c2)
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := New(0xD010)
+	asserted := false
+	p.B.IRQ = func(a bool) {
+		asserted = a
+	}
+	p.Set(0xD013, 0x07)
+	p.B.SetC1(true)
+	p.Reset()
+	if asserted {
+		t.Error("IRQB still asserted after a reset")
+	}
+	if v, _ := p.Peek(0xD013); v != 0x00 {
+		t.Errorf("CRB $%02x after a reset", v)
+	}
+}
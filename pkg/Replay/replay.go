@@ -0,0 +1,111 @@
+package Replay
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+	Input recordings are plain text, one key per line:
+
+	# go6502 input recording
+	<cycle> <key>
+
+	cycle is the decimal CPU cycle count at which the key was handed to the
+	keyboard, key is the byte as two hex digits.  Lines starting with # are
+	comments.
+*/
+
+const header = "# go6502 input recording\n"
+
+type Event struct {
+	Cycle uint64
+	Key   byte
+}
+
+type Recorder struct {
+	file *os.File
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Recorder{
+		file: f,
+	}, nil
+}
+
+// Record writes the event straight to disk, so a crash or a hard quit
+// still leaves a usable recording behind
+func (r *Recorder) Record(cycle uint64, key byte) error {
+	_, err := fmt.Fprintf(r.file, "%d %02x\n", cycle, key)
+	return err
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+type Player struct {
+	events []Event
+	next   int
+}
+
+func Load(path string) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &Player{
+		events: make([]Event, 0),
+	}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		t := strings.TrimSpace(scanner.Text())
+		if len(t) == 0 || t[0] == '#' {
+			continue
+		}
+		var e Event
+		if _, err := fmt.Sscanf(t, "%d %x", &e.Cycle, &e.Key); err != nil {
+			return nil, fmt.Errorf("%v:%v: invalid event %q: %v", path, line, t, err)
+		}
+		if n := len(p.events); n > 0 && e.Cycle < p.events[n-1].Cycle {
+			return nil, fmt.Errorf("%v:%v: cycle %v is before the previous event", path, line, e.Cycle)
+		}
+		p.events = append(p.events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Due returns the keys that became available at or before cycle, in order
+func (p *Player) Due(cycle uint64) []byte {
+	var keys []byte
+	for p.next < len(p.events) && p.events[p.next].Cycle <= cycle {
+		keys = append(keys, p.events[p.next].Key)
+		p.next++
+	}
+	return keys
+}
+
+func (p *Player) Done() bool {
+	return p.next >= len(p.events)
+}
+
+func (p *Player) Len() int {
+	return len(p.events)
+}
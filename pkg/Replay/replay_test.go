@@ -0,0 +1,67 @@
+package Replay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	r, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{{100, 'A'}, {100, 'B'}, {2500, 0x0D}}
+	for _, e := range events {
+		if err := r.Record(e.Cycle, e.Key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != len(events) {
+		t.Fatalf("loaded %v events, recorded %v", p.Len(), len(events))
+	}
+	if keys := p.Due(99); len(keys) != 0 {
+		t.Fatalf("keys %q due before the first event", keys)
+	}
+	if keys := string(p.Due(2499)); keys != "AB" {
+		t.Fatalf("due by 2499 %q, want \"AB\"", keys)
+	}
+	if p.Done() {
+		t.Fatal("done with a key left")
+	}
+	if keys := string(p.Due(10000)); keys != "\r" {
+		t.Fatalf("due by 10000 %q, want \"\\r\"", keys)
+	}
+	if !p.Done() {
+		t.Fatal("not done after the last key")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		err  string
+	}{
+		{"100 zz\n", "invalid event"},
+		{"200 41\n100 42\n", "before the previous event"},
+	} {
+		path := filepath.Join(t.TempDir(), "keys.txt")
+		if err := os.WriteFile(path, []byte(header+c.text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: got %v, want %q", c.text, err, c.err)
+		}
+	}
+}
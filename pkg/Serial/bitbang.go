@@ -0,0 +1,198 @@
+package Serial
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+/*
+	BitBang is the far end of a software UART, a program toggling a port
+	pin for TX and watching another for RX, like rom/archive/uart_6522.asm
+	does with a VIA.
+
+	TX is sampled in the middle of each bit, a falling edge starts a
+	character and it's sent to the host once its stop bit is seen.  Bytes
+	from the host go out on RX back to back, start bit, data LSB first,
+	parity and stop bits, with the pin changing on instruction boundaries.
+	Both are events on the machine's Clock, a bit every Hz / Baud cycles.
+*/
+
+type BitBang struct {
+	Baud   float64
+	Data   int  // data bits, 5 to 8
+	Parity byte // 'N', 'E', 'O', 'M' or 'S'
+	Stop   int  // stop bits
+
+	RX func(level bool) // the RX pins changed
+
+	host  *Host
+	clock *IO.Clock
+
+	tx      bool   // the TX pin
+	txStart uint64 // when the start bit began
+	txBit   int    // next bit to sample, -1 while idle
+	txByte  byte
+
+	rx bool
+}
+
+func NewBitBang(clock *IO.Clock) *BitBang {
+	return &BitBang{
+		clock:  clock,
+		Baud:   1200,
+		Data:   8,
+		Parity: 'N',
+		Stop:   1,
+		tx:     true,
+		txBit:  -1,
+		rx:     true,
+	}
+}
+
+// SetFormat takes a format like "8N1"
+func (b *BitBang) SetFormat(format string) error {
+	var data, stop int
+	var parity byte
+	if _, err := fmt.Sscanf(format, "%1d%c%1d", &data, &parity, &stop); err != nil {
+		return fmt.Errorf("invalid serial format %q, expected something like 8N1", format)
+	}
+	switch {
+	case data < 5 || data > 8:
+		return fmt.Errorf("invalid serial format %q, 5 to 8 data bits", format)
+	case parity != 'N' && parity != 'E' && parity != 'O' && parity != 'M' && parity != 'S':
+		return fmt.Errorf("invalid serial format %q, parity is N, E, O, M or S", format)
+	case stop < 1 || stop > 2:
+		return fmt.Errorf("invalid serial format %q, 1 or 2 stop bits", format)
+	}
+	b.Data, b.Parity, b.Stop = data, parity, stop
+	return nil
+}
+
+// Connect wires the line to the host
+func (b *BitBang) Connect(host *Host) {
+	b.host = host
+	b.listen()
+}
+
+// SetTX is the program driving the TX pin
+func (b *BitBang) SetTX(level bool) {
+	if level == b.tx {
+		return
+	}
+	b.tx = level
+	if !level && b.txBit < 0 {
+		b.txStart = b.clock.Now()
+		b.txBit = 0
+		b.txByte = 0
+		b.schedule()
+	}
+}
+
+// Level is what's on the RX pins
+func (b *BitBang) Level() bool {
+	return b.rx
+}
+
+// schedule samples TX in the middle of bit txBit
+func (b *BitBang) schedule() {
+	at := (float64(b.txBit) + 0.5) * b.bitTime()
+	b.clock.At(b.txStart+uint64(at), b.sample)
+}
+
+// listen looks for a byte from the host every bit time while RX is idle
+func (b *BitBang) listen() {
+	if v, ok := b.host.Receive(); ok {
+		b.send(v)
+		return
+	}
+	b.clock.After(uint64(max(1, b.bitTime())), b.listen)
+}
+
+// send puts a character on RX a bit at a time, and listens again once the
+// last stop bit has had its time
+func (b *BitBang) send(v byte) {
+	start := b.clock.Now()
+	bit := b.bitTime()
+	f := b.frame(v)
+	for i, level := range f {
+		level := level
+		b.clock.At(start+uint64(float64(i)*bit), func() { b.drive(level) })
+	}
+	b.clock.At(start+uint64(float64(len(f))*bit), b.listen)
+}
+
+func (b *BitBang) bitTime() float64 {
+	return b.clock.Hz / b.Baud
+}
+
+// sample reads TX in the middle of bit txBit of the character
+func (b *BitBang) sample() {
+	n := b.txBit
+	b.txBit++
+	parity := 0
+	if b.Parity != 'N' {
+		parity = 1
+	}
+	switch {
+	case n == 0:
+		if b.tx {
+			// a glitch, not a start bit
+			b.txBit = -1
+			return
+		}
+	case n <= b.Data:
+		if b.tx {
+			b.txByte |= 1 << (n - 1)
+		}
+	case n <= b.Data+parity:
+		// the parity isn't checked
+	default:
+		b.txBit = -1
+		if !b.tx {
+			logger.Debugf("bit bang: framing error, $%02x", b.txByte)
+			return
+		}
+		if b.host != nil {
+			b.host.Send(b.txByte)
+		}
+		return
+	}
+	b.schedule()
+}
+
+func (b *BitBang) frame(v byte) []bool {
+	f := []bool{false}
+	ones := 0
+	for i := 0; i < b.Data; i++ {
+		bit := v&(1<<i) != 0
+		if bit {
+			ones++
+		}
+		f = append(f, bit)
+	}
+	switch b.Parity {
+	case 'E':
+		f = append(f, ones%2 == 1)
+	case 'O':
+		f = append(f, ones%2 == 0)
+	case 'M':
+		f = append(f, true)
+	case 'S':
+		f = append(f, false)
+	}
+	for i := 0; i < b.Stop; i++ {
+		f = append(f, true)
+	}
+	return f
+}
+
+func (b *BitBang) drive(level bool) {
+	if level == b.rx {
+		return
+	}
+	b.rx = level
+	if b.RX != nil {
+		b.RX(level)
+	}
+}
@@ -0,0 +1,88 @@
+package Serial
+
+import (
+	"testing"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+// 1200 baud on a 1.2MHz clock is 1000 cycles a bit
+func newBitBang(format string) (*BitBang, *IO.Clock, *Host) {
+	clock := IO.NewClock(1_200_000)
+	b := NewBitBang(clock)
+	b.SetFormat(format)
+	host := &Host{output: make(chan byte, 8)}
+	b.Connect(host)
+	return b, clock, host
+}
+
+func TestBitBangTX(t *testing.T) {
+	b, clock, host := newBitBang("7E1")
+	clock.Advance(5000)
+	// 'A' 7E1, start, 1000001 LSB first, even parity, stop, with the pin
+	// jittering a little like a program's loop would
+	for i, level := range []bool{false, true, false, false, false, false, false, true, false, true} {
+		b.SetTX(level)
+		clock.Advance(uint64(1000 + i%3*20 - 20))
+	}
+	select {
+	case v := <-host.output:
+		if v != 'A' {
+			t.Errorf("host got $%02x, want 'A'", v)
+		}
+	default:
+		t.Fatal("nothing sent to the host")
+	}
+}
+
+func TestBitBangFraming(t *testing.T) {
+	b, clock, host := newBitBang("8N1")
+	b.SetTX(false)
+	clock.Advance(10_000) // no stop bit
+	b.SetTX(true)
+	clock.Advance(10_000)
+	select {
+	case v := <-host.output:
+		t.Errorf("host got $%02x with a framing error", v)
+	default:
+	}
+}
+
+func TestBitBangRX(t *testing.T) {
+	b, clock, host := newBitBang("8O2")
+	var levels []bool
+	host.Type([]byte{0x0F})
+	// sample RX in the middle of each bit from the start bit on
+	b.RX = func(level bool) {
+		if !level && len(levels) == 0 {
+			for i := 0; i < 12; i++ {
+				clock.At(clock.Now()+uint64(i)*1000+500, func() {
+					levels = append(levels, b.Level())
+				})
+			}
+		}
+	}
+	clock.Advance(20_000)
+	// start, $0F LSB first, odd parity, 2 stop
+	want := []bool{false, true, true, true, true, false, false, false, false, true, true, true}
+	if len(levels) != len(want) {
+		t.Fatalf("sampled %v bits, want %v", len(levels), len(want))
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Fatalf("RX was %v, want %v", levels, want)
+		}
+	}
+}
+
+func TestSetFormat(t *testing.T) {
+	b := NewBitBang(IO.NewClock(1_000_000))
+	if err := b.SetFormat("7E2"); err != nil || b.Data != 7 || b.Parity != 'E' || b.Stop != 2 {
+		t.Errorf("7E2 set %v%c%v, %v", b.Data, b.Parity, b.Stop, err)
+	}
+	for _, f := range []string{"9N1", "8X1", "8N3", "fast"} {
+		if err := b.SetFormat(f); err == nil {
+			t.Errorf("set format %q", f)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package Serial
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// openPTY makes a pseudo-terminal and returns both sides and the slave's
+// path.  The slave is held open in raw mode so the master doesn't
+// see hangups between clients, and a script that doesn't set the line up
+// still gets every byte as it is.
+func openPTY() (*os.File, *os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("unlock: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("ptsname: %v", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	if _, err := term.MakeRaw(int(slave.Fd())); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, nil, "", fmt.Errorf("raw mode: %v", err)
+	}
+	return master, slave, name, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package Serial
+
+import (
+	"fmt"
+	"os"
+)
+
+func openPTY() (*os.File, *os.File, string, error) {
+	return nil, nil, "", fmt.Errorf("only supported on Linux, use tcp:ADDR instead")
+}
@@ -0,0 +1,186 @@
+package Serial
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/Log"
+)
+
+var logger = Log.For("serial")
+
+/*
+	The host's end of an emulated serial port, what the UART's TX and RX
+	pins are wired to:
+
+	pty          a new pseudo-terminal, point minicom or a script at the
+	             /dev/pts/N it prints (Linux only)
+	stdio        stdin and stdout
+	tcp:ADDR     a TCP listener, "tcp:6551" listens on localhost, a newer
+	             client takes over from an older one
+
+	Bytes from the host are queued until the UART takes them, it decides
+	how fast they arrive.  Bytes to the host never block the CPU, if the
+	other end falls a buffer behind it loses output.
+*/
+
+// Device is a UART that can be wired to a Host
+type Device interface {
+	Connect(host *Host)
+}
+
+type Host struct {
+	Name string // where to connect, the pty's path or the listening address
+
+	output  chan byte
+	unsent  atomic.Int32 // queued or being written
+	closers []io.Closer
+
+	mutex sync.Mutex
+	rx    []byte
+	conn  net.Conn // the tcp client
+}
+
+// Open starts a host end, spec is pty, stdio or tcp:ADDR
+func Open(spec string) (*Host, error) {
+	h := &Host{output: make(chan byte, 8192)}
+	switch {
+	case spec == "pty":
+		master, slave, name, err := openPTY()
+		if err != nil {
+			return nil, fmt.Errorf("pty: %v", err)
+		}
+		h.Name = name
+		h.closers = append(h.closers, master, slave)
+		go h.read(master)
+		go h.write(master)
+	case spec == "stdio":
+		h.Name = "stdio"
+		go h.read(os.Stdin)
+		go h.write(os.Stdout)
+	case strings.HasPrefix(spec, "tcp:"):
+		addr := strings.TrimPrefix(spec, "tcp:")
+		if !strings.Contains(addr, ":") {
+			addr = "localhost:" + addr
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		h.Name = l.Addr().String()
+		h.closers = append(h.closers, l)
+		go h.accept(l)
+		go h.write(h)
+	default:
+		return nil, fmt.Errorf("unknown serial port %q, expected pty, stdio or tcp:ADDR", spec)
+	}
+	logger.Infof("serial port on %v", h.Name)
+	return h, nil
+}
+
+// Close gives the output a moment to drain and shuts the host end down
+func (h *Host) Close() error {
+	for wait := 0; h.unsent.Load() > 0 && wait < 500; wait++ {
+		time.Sleep(time.Millisecond)
+	}
+	h.mutex.Lock()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	h.mutex.Unlock()
+	var err error
+	for _, c := range h.closers {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Receive takes the next byte from the host
+func (h *Host) Receive() (byte, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.rx) == 0 {
+		return 0, false
+	}
+	b := h.rx[0]
+	h.rx = h.rx[1:]
+	return b, true
+}
+
+// Pending is true while there's a byte waiting for Receive
+func (h *Host) Pending() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.rx) > 0
+}
+
+// Send queues a byte for the host, it's dropped if the queue is full
+func (h *Host) Send(b byte) {
+	h.unsent.Add(1)
+	select {
+	case h.output <- b:
+	default:
+		h.unsent.Add(-1)
+	}
+}
+
+// Type queues bytes as if the host had sent them
+func (h *Host) Type(data []byte) {
+	h.mutex.Lock()
+	h.rx = append(h.rx, data...)
+	h.mutex.Unlock()
+}
+
+func (h *Host) read(r io.Reader) {
+	buffer := make([]byte, 256)
+	for {
+		n, err := r.Read(buffer)
+		h.Type(buffer[:n])
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *Host) write(w io.Writer) {
+	for b := range h.output {
+		w.Write([]byte{b})
+		h.unsent.Add(-1)
+	}
+}
+
+func (h *Host) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		logger.Infof("serial client %v", conn.RemoteAddr())
+		h.mutex.Lock()
+		if h.conn != nil {
+			h.conn.Close()
+		}
+		h.conn = conn
+		h.mutex.Unlock()
+		go h.read(conn)
+	}
+}
+
+// Write goes to the tcp client, output is thrown away while there isn't one
+func (h *Host) Write(data []byte) (int, error) {
+	h.mutex.Lock()
+	conn := h.conn
+	h.mutex.Unlock()
+	if conn == nil {
+		return len(data), nil
+	}
+	return conn.Write(data)
+}
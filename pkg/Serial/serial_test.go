@@ -0,0 +1,64 @@
+package Serial
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("com1"); err == nil {
+		t.Error("opened com1")
+	}
+}
+
+// TestTakeover connects a second client, it gets the port and the first
+// one is hung up on
+func TestTakeover(t *testing.T) {
+	h, err := Open("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	first, err := net.Dial("tcp", h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	first.Write([]byte("1"))
+	receive(t, h, '1')
+
+	second, err := net.Dial("tcp", h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.Write([]byte("2"))
+	receive(t, h, '2')
+
+	first.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := first.Read(make([]byte, 1)); err == nil {
+		t.Error("the first client is still connected")
+	}
+	h.Send('3')
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1)
+	if _, err := second.Read(b); err != nil || b[0] != '3' {
+		t.Errorf("second client read %q, %v, want '3'", b, err)
+	}
+}
+
+func receive(t *testing.T, h *Host, want byte) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if b, ok := h.Receive(); ok {
+			if b != want {
+				t.Fatalf("received %q, want %q", b, want)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("nothing received, want %q", want)
+}
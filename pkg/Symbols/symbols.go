@@ -0,0 +1,103 @@
+package Symbols
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Symbol tables from the VICE label files ld65 writes with -Ln, see
+	rom/build:
+
+	al 00F000 .RESET
+	al 00FF1F .GETLINE
+
+	Labels starting with @ (cheap locals) and __ (linker generated) are
+	skipped.
+*/
+
+type Table struct {
+	names map[uint16]string
+	addrs []uint16 // sorted, for Describe
+}
+
+// near is how far past a label Describe still names it
+const near = 0x100
+
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &Table{
+		names: make(map[uint16]string),
+	}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || fields[0] != "al" {
+			return nil, fmt.Errorf("%v:%v: expected \"al ADDR .NAME\"", path, n)
+		}
+		addr, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil || addr > 0xFFFF {
+			return nil, fmt.Errorf("%v:%v: invalid address %q", path, n, fields[1])
+		}
+		name := strings.TrimPrefix(fields[2], ".")
+		if strings.HasPrefix(name, "@") || strings.HasPrefix(name, "__") {
+			continue
+		}
+		// keep the first of several names for an address
+		if _, ok := t.names[uint16(addr)]; !ok {
+			t.names[uint16(addr)] = name
+			t.addrs = append(t.addrs, uint16(addr))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(t.addrs, func(i, j int) bool {
+		return t.addrs[i] < t.addrs[j]
+	})
+	return t, nil
+}
+
+func (t *Table) Len() int {
+	return len(t.addrs)
+}
+
+// Lookup returns the label at exactly addr
+func (t *Table) Lookup(addr uint16) (string, bool) {
+	name, ok := t.names[addr]
+	return name, ok
+}
+
+// Describe names addr by the closest label at or below it, "GETLINE+3",
+// or returns "" when there isn't one nearby.  A nil Table has no labels.
+func (t *Table) Describe(addr uint16) string {
+	if t == nil {
+		return ""
+	}
+	i := sort.Search(len(t.addrs), func(i int) bool {
+		return t.addrs[i] > addr
+	})
+	if i == 0 {
+		return ""
+	}
+	base := t.addrs[i-1]
+	if addr-base >= near {
+		return ""
+	}
+	if addr == base {
+		return t.names[base]
+	}
+	return fmt.Sprintf("%v+%v", t.names[base], addr-base)
+}
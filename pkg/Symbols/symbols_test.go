@@ -0,0 +1,57 @@
+package Symbols
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func load(t *testing.T, labels string) (*Table, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rom.lbl")
+	if err := os.WriteFile(path, []byte(labels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestDescribe(t *testing.T) {
+	table, err := load(t, `al 00FF1F .GETLINE
+al 00F000 .RESET
+al 00F000 .START
+al 00F010 .@loop
+al 00F020 .__BSS_RUN__
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != 2 {
+		t.Errorf("%v labels, want RESET and GETLINE", table.Len())
+	}
+	if name, ok := table.Lookup(0xF000); !ok || name != "RESET" {
+		t.Errorf("$F000 is %q, want the first label, RESET", name)
+	}
+	for addr, want := range map[uint16]string{
+		0xF000: "RESET",
+		0xF010: "RESET+16",
+		0xFF22: "GETLINE+3",
+		0xEFFF: "",
+		0xF100: "", // too far from RESET
+	} {
+		if s := table.Describe(addr); s != want {
+			t.Errorf("$%04x described as %q, want %q", addr, s, want)
+		}
+	}
+	var none *Table
+	if s := none.Describe(0xF000); s != "" {
+		t.Errorf("a nil table described $F000 as %q", s)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, labels := range []string{"al F000", "al 10000 .HIGH", "xx 00F000 .RESET"} {
+		if _, err := load(t, labels); err == nil {
+			t.Errorf("loaded %q", labels)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package Telnet
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/zoul0813/go6502/pkg/Display"
+)
+
+// Telnet commands and options, RFC 854 / 857 / 858
+const (
+	SE   = 240
+	SB   = 250
+	WILL = 251
+	WONT = 252
+	DO   = 253
+	DONT = 254
+	IAC  = 255
+
+	ECHO = 1
+	SGA  = 3 // suppress go ahead
+)
+
+type Mode int
+
+const (
+	Shared    Mode = iota // every client can type
+	Exclusive             // only the oldest client can type, the rest watch
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "shared":
+		return Shared, nil
+	case "exclusive":
+		return Exclusive, nil
+	}
+	return Shared, fmt.Errorf("unknown input mode %q, expected shared or exclusive", s)
+}
+
+func (m Mode) String() string {
+	if m == Exclusive {
+		return "exclusive"
+	}
+	return "shared"
+}
+
+type Server struct {
+	Mode   Mode
+	Telnet bool // negotiate telnet options, false serves raw TCP
+
+	OnKey  func(key byte) // a byte typed by a client, CR for return
+	Screen func() string  // current screen, sent to new clients
+
+	listener net.Listener
+	mutex    sync.Mutex
+	clients  []*client // oldest first
+}
+
+type client struct {
+	conn   net.Conn
+	output chan byte
+}
+
+func New(display *Display.Display, mode Mode) *Server {
+	s := &Server{
+		Mode:    mode,
+		Telnet:  true,
+		clients: make([]*client, 0),
+	}
+	display.Listen(s.broadcast)
+	return s
+}
+
+func (s *Server) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	go s.accept()
+	return nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	for _, c := range s.clients {
+		c.conn.Close()
+	}
+	s.mutex.Unlock()
+	return s.listener.Close()
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &client{
+			conn:   conn,
+			output: make(chan byte, 8192),
+		}
+		s.mutex.Lock()
+		s.clients = append(s.clients, c)
+		s.mutex.Unlock()
+		go s.serve(c)
+	}
+}
+
+// broadcast runs on the CPU goroutine with the display locked, so it never
+// blocks; a client that falls more than a buffer behind loses output
+func (s *Server) broadcast(c byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, cl := range s.clients {
+		if c == 0x0D {
+			cl.send('\r')
+			cl.send('\n')
+		} else {
+			cl.send(c)
+		}
+	}
+}
+
+func (c *client) send(b byte) {
+	select {
+	case c.output <- b:
+	default:
+	}
+}
+
+func (s *Server) serve(c *client) {
+	defer s.drop(c)
+
+	if s.Telnet {
+		// we echo, and there's no need for go ahead in character mode
+		c.conn.Write([]byte{IAC, WILL, ECHO, IAC, WILL, SGA, IAC, DO, SGA})
+	}
+	input := "shared"
+	if s.Mode == Exclusive && !s.owns(c) {
+		input = "view only"
+	}
+	greeting := fmt.Sprintf("Go6502 Apple-1 (input: %v)\r\n\r\n", input)
+	if s.Screen != nil {
+		greeting += screen(s.Screen())
+	}
+	if _, err := c.conn.Write([]byte(greeting)); err != nil {
+		return
+	}
+
+	go c.write()
+
+	buffer := make([]byte, 256)
+	r := reader{telnet: s.Telnet}
+	for {
+		n, err := c.conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		for _, b := range buffer[:n] {
+			key, ok := r.next(b)
+			if !ok || s.OnKey == nil {
+				continue
+			}
+			if s.Mode == Exclusive && !s.owns(c) {
+				continue
+			}
+			s.OnKey(key)
+		}
+	}
+}
+
+func (c *client) write() {
+	for b := range c.output {
+		if _, err := c.conn.Write([]byte{b}); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (s *Server) owns(c *client) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.clients) > 0 && s.clients[0] == c
+}
+
+func (s *Server) drop(c *client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, cl := range s.clients {
+		if cl == c {
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			break
+		}
+	}
+	close(c.output)
+	c.conn.Close()
+}
+
+// screen converts the display text for a telnet client, trailing blanks
+// are dropped so the client's cursor ends up after the last character
+func screen(all string) string {
+	lines := strings.Split(all, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \x00")
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// reader strips telnet commands from the input stream and folds the CR LF
+// and CR NUL line endings clients send for return into a single CR
+type reader struct {
+	telnet bool
+	state  int
+	cr     bool
+}
+
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+func (r *reader) next(b byte) (byte, bool) {
+	switch r.state {
+	case stateIAC:
+		switch b {
+		case IAC:
+			r.state = stateData
+			return b, true
+		case WILL, WONT, DO, DONT:
+			r.state = stateOption
+		case SB:
+			r.state = stateSub
+		default:
+			r.state = stateData
+		}
+		return 0, false
+	case stateOption:
+		r.state = stateData
+		return 0, false
+	case stateSub:
+		if b == IAC {
+			r.state = stateSubIAC
+		}
+		return 0, false
+	case stateSubIAC:
+		if b == SE {
+			r.state = stateData
+		} else {
+			r.state = stateSub
+		}
+		return 0, false
+	}
+
+	if r.telnet && b == IAC {
+		r.state = stateIAC
+		return 0, false
+	}
+	cr := r.cr
+	r.cr = b == '\r'
+	if cr && (b == '\n' || b == 0x00) {
+		return 0, false
+	}
+	if b == '\r' || b == '\n' {
+		return 0x0D, true
+	}
+	return b, true
+}
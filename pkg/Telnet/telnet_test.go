@@ -0,0 +1,94 @@
+package Telnet
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/Display"
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+func TestReader(t *testing.T) {
+	r := reader{telnet: true}
+	in := []byte{
+		IAC, DO, ECHO, 'h', 'i',
+		IAC, SB, 24, 0, 'x', 't', 'e', 'r', 'm', IAC, SE,
+		'\r', '\n', 'a', '\r', 0x00, 'b', '\n',
+		IAC, IAC,
+	}
+	var keys []byte
+	for _, b := range in {
+		if k, ok := r.next(b); ok {
+			keys = append(keys, k)
+		}
+	}
+	if want := "hi\ra\rb\r\xFF"; string(keys) != want {
+		t.Errorf("keys %q, want %q", keys, want)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	if s := screen("HELLO   \n\\       \n        \n"); s != "HELLO\r\n\\" {
+		t.Errorf("screen %q", s)
+	}
+}
+
+// dial connects a client and reads its greeting up to the blank line
+func dial(t *testing.T, s *Server) (net.Conn, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ReadString('\n')
+	return conn, greeting
+}
+
+func TestExclusive(t *testing.T) {
+	d := Display.New(40, 24, IO.NewClock(1_000_000))
+	s := New(d, Exclusive)
+	keys := make(chan byte, 16)
+	s.OnKey = func(key byte) {
+		keys <- key
+	}
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	owner, greeting := dial(t, s)
+	defer owner.Close()
+	if !strings.Contains(greeting, "input: shared") {
+		t.Errorf("the first client was greeted with %q", greeting)
+	}
+	viewer, greeting := dial(t, s)
+	defer viewer.Close()
+	if !strings.Contains(greeting, "view only") {
+		t.Errorf("the second client was greeted with %q", greeting)
+	}
+
+	viewer.Write([]byte("X"))
+	owner.Write([]byte("A"))
+	select {
+	case k := <-keys:
+		if k != 'A' {
+			t.Fatalf("typed %q, want only the owner's 'A'", k)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the owner's key never arrived")
+	}
+	select {
+	case k := <-keys:
+		t.Errorf("typed %q from the viewer", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,179 @@
+package Terminal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/Display"
+	"golang.org/x/term"
+)
+
+// Control keys handled by the terminal instead of being typed on the
+// Apple-1 keyboard
+const (
+	CtrlC = 0x03 // quit
+	CtrlD = 0x04 // debugger
+	CtrlQ = 0x11 // quit
+	CtrlR = 0x12 // reset
+)
+
+const (
+	frameRate = 30
+	blinkRate = time.Millisecond * 500
+)
+
+type Terminal struct {
+	display *Display.Display
+	in      *os.File
+	out     *os.File
+	state   *term.State
+	dirty   atomic.Bool
+	mutex   sync.Mutex // guards out and state
+	quit    chan struct{}
+
+	OnKey   func(key byte) // a byte typed on the tty, see Keyboard.Translate
+	OnReset func()
+	OnDebug func() // runs with the terminal in cooked mode
+}
+
+func New(display *Display.Display) *Terminal {
+	t := &Terminal{
+		display: display,
+		in:      os.Stdin,
+		out:     os.Stdout,
+		quit:    make(chan struct{}),
+	}
+	display.Listen(func(c byte) {
+		t.dirty.Store(true)
+	})
+	return t
+}
+
+// Run puts the tty in raw mode and draws the display until quit is pressed
+func (t *Terminal) Run() error {
+	if !term.IsTerminal(int(t.in.Fd())) {
+		return fmt.Errorf("stdin is not a terminal")
+	}
+	if err := t.raw(); err != nil {
+		return err
+	}
+	defer t.restore()
+
+	fmt.Fprint(t.out, "\033[2J\033[?25l") // clear, hide the host cursor
+	defer fmt.Fprint(t.out, "\033[?25h\r\n")
+
+	go t.read()
+
+	frame := time.NewTicker(time.Second / frameRate)
+	defer frame.Stop()
+	blink := false
+	lastBlink := time.Now()
+	t.draw(blink)
+	for {
+		select {
+		case <-t.quit:
+			return nil
+		case now := <-frame.C:
+			if now.Sub(lastBlink) >= blinkRate {
+				blink = !blink
+				lastBlink = now
+				t.dirty.Store(true)
+			}
+			if t.dirty.Swap(false) {
+				t.draw(blink)
+			}
+		}
+	}
+}
+
+func (t *Terminal) read() {
+	buffer := make([]byte, 64)
+	for {
+		n, err := t.in.Read(buffer)
+		if err != nil {
+			close(t.quit)
+			return
+		}
+		for _, b := range buffer[:n] {
+			switch b {
+			case CtrlC, CtrlQ:
+				close(t.quit)
+				return
+			case CtrlR:
+				if t.OnReset != nil {
+					t.OnReset()
+				}
+			case CtrlD:
+				if t.OnDebug != nil {
+					t.debug()
+				}
+			default:
+				if t.OnKey != nil {
+					t.OnKey(b)
+				}
+			}
+		}
+	}
+}
+
+// debug hands the tty back to the line based debug console, drawing is
+// paused until it returns
+func (t *Terminal) debug() {
+	t.mutex.Lock()
+	fmt.Fprint(t.out, "\033[2J\033[H\033[?25h")
+	t.restoreLocked()
+	t.mutex.Unlock()
+
+	t.OnDebug()
+
+	t.raw()
+	fmt.Fprint(t.out, "\033[2J\033[?25l")
+	t.dirty.Store(true)
+}
+
+func (t *Terminal) draw(blink bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state == nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	lines := strings.Split(strings.TrimSuffix(t.display.All(blink), "\n"), "\n")
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\033[K\r\n")
+	}
+	b.WriteString("\033[2m^R reset  ^D debug  ^Q quit\033[0m\033[K")
+	fmt.Fprint(t.out, b.String())
+}
+
+func (t *Terminal) raw() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		return err
+	}
+	t.state = state
+	return nil
+}
+
+func (t *Terminal) restore() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.restoreLocked()
+}
+
+func (t *Terminal) restoreLocked() {
+	if t.state == nil {
+		return
+	}
+	term.Restore(int(t.in.Fd()), t.state)
+	t.state = nil
+}
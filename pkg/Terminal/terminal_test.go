@@ -0,0 +1,45 @@
+package Terminal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zoul0813/go6502/pkg/Display"
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+// TestKeys types through a pipe, the control keys are the terminal's and
+// everything else goes to OnKey as it is
+func TestKeys(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	tty := New(Display.New(40, 24, IO.NewClock(1_000_000)))
+	tty.in = r
+	var keys []byte
+	resets := 0
+	tty.OnKey = func(key byte) {
+		keys = append(keys, key)
+	}
+	tty.OnReset = func() {
+		resets++
+	}
+
+	go tty.read()
+	w.Write([]byte{'a', '\n', CtrlR, 0x7F, CtrlQ, 'z'})
+	select {
+	case <-tty.quit:
+	case <-time.After(5 * time.Second):
+		t.Fatal("^Q didn't quit")
+	}
+	w.Close()
+	if string(keys) != "a\n\x7F" {
+		t.Errorf("typed %q, want \"a\\n\\x7f\"", keys)
+	}
+	if resets != 1 {
+		t.Errorf("reset %v times, want 1", resets)
+	}
+}
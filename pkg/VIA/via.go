@@ -0,0 +1,548 @@
+package VIA
+
+import (
+	"fmt"
+
+	"github.com/zoul0813/go6502/pkg/Log"
+)
+
+var logger = Log.For("via")
+
+/*
+	The MOS 6522 Versatile Interface Adapter.
+
+	0  ORB/IRB   port B, clears the CB1/CB2 flags, CB2 handshakes on writes
+	1  ORA/IRA   port A, clears the CA1/CA2 flags, CA2 handshakes
+	2  DDRB      1 bits are outputs
+	3  DDRA
+	4  T1C-L     read the counter and clear the T1 flag, write the latch
+	5  T1C-H     write the latch and start T1 from it, clearing the flag
+	6  T1L-L     the latch
+	7  T1L-H     the latch, clearing the T1 flag
+	8  T2C-L     read the counter and clear the T2 flag, write the latch
+	9  T2C-H     start T2 from the latch, clearing the flag
+	A  SR        shift register, an access starts 8 more shifts
+	B  ACR       b7 T1 drives PB7, b6 T1 free run, b5 T2 counts PB6
+	             falling edges, b4-b2 SR mode, b1/b0 latch port B/A on CB1/CA1
+	C  PCR       b7-b5 CB2, b4 CB1 rising edge, b3-b1 CA2, b0 CA1 rising edge
+	             C2 modes: 000 falling edge input, 001 the same but
+	             independent of port accesses, 010/011 rising edge, 100
+	             handshake, 101 pulse, 110 low, 111 high
+	D  IFR       b7 IRQ, b6 T1, b5 T2, b4 CB1, b3 CB2, b2 SR, b1 CA1, b0 CA2,
+	             write 1s to clear
+	E  IER       write b7 set to enable the 1 bits, clear to disable them
+	F  ORA/IRA   port A without the handshake
+
+	Nothing happens between CPU accesses until Tick is called with the
+	cycles that have gone by.  T1 times out N+1 cycles after it's started
+	with N, and free runs every N+2.  The shift register's T2 rate comes
+	from the T2 latch low byte, a bit every 2*(N+2) cycles, and the Φ2 rate
+	is a bit every 2 cycles.
+*/
+
+const (
+	ifrCA2 = 1 << iota
+	ifrCA1
+	ifrSR
+	ifrCB2
+	ifrCB1
+	ifrT2
+	ifrT1
+	ifrIRQ
+)
+
+type VIA struct {
+	A      *Port
+	B      *Port
+	IRQ    func(asserted bool) // the IRQ output changed
+	offset uint16
+
+	acr byte
+	pcr byte
+	ifr byte
+	ier byte
+	irq bool
+
+	t1      uint16
+	t1l     uint16
+	t1armed bool // one shot, an interrupt still to come
+	t1load  bool // free run, reload the latch next cycle
+	pb7     bool
+
+	t2      uint16
+	t2l     byte
+	t2armed bool
+	pb6     bool
+
+	sr     byte
+	srBits int // shifts left, 0 when stopped
+	srTick int // cycles to the next shift
+}
+
+type Port struct {
+	Name string
+	DDR  byte
+	OR   byte
+
+	// peripheral wiring, any of these can be nil
+	Input  func() byte      // the pins, when the CPU reads the port
+	Output func(value byte) // the output pins, when OR or DDR change
+	C2     func(level bool) // C2 as an output changed, and SR output on CB2
+
+	via   *VIA
+	b     bool
+	latch byte // IR latched on the C1 edge
+	c1    bool
+	c2    bool
+	out   bool // C2 as an output
+}
+
+func New(offset uint16) *VIA {
+	v := &VIA{offset: offset}
+	v.A = &Port{Name: "A", via: v, out: true}
+	v.B = &Port{Name: "B", via: v, b: true, out: true}
+	return v
+}
+
+// IO.Memory Interface
+func (v *VIA) Size() uint16 {
+	return 0x0F
+}
+
+func (v *VIA) Get(addr uint16) (byte, error) {
+	switch (addr - v.offset) & 0x0F {
+	case 0x0:
+		b := v.B.read()
+		v.B.access(false)
+		return b, nil
+	case 0x1:
+		b := v.A.read()
+		v.A.access(true)
+		return b, nil
+	case 0x4:
+		v.clear(ifrT1)
+		return byte(v.t1), nil
+	case 0x8:
+		v.clear(ifrT2)
+		return byte(v.t2), nil
+	case 0xA:
+		v.clear(ifrSR)
+		v.startShift()
+		return v.sr, nil
+	}
+	return v.Peek(addr)
+}
+
+func (v *VIA) GetWord(addr uint16) (uint16, error) {
+	lo, _ := v.Get(addr)
+	hi, _ := v.Get(addr + 1)
+	return uint16(hi)<<8 | uint16(lo), nil
+}
+
+func (v *VIA) Set(addr uint16, value byte) error {
+	switch (addr - v.offset) & 0x0F {
+	case 0x0:
+		v.B.OR = value
+		v.B.output()
+		v.B.access(true)
+	case 0x1:
+		v.A.OR = value
+		v.A.output()
+		v.A.access(true)
+	case 0x2:
+		v.B.DDR = value
+		v.B.output()
+	case 0x3:
+		v.A.DDR = value
+		v.A.output()
+	case 0x4, 0x6:
+		v.t1l = v.t1l&0xFF00 | uint16(value)
+	case 0x5:
+		v.t1l = v.t1l&0x00FF | uint16(value)<<8
+		v.t1 = v.t1l
+		v.t1armed = true
+		v.t1load = false
+		v.clear(ifrT1)
+		if v.acr&0x80 != 0 {
+			v.pb7 = false
+			v.B.output()
+		}
+	case 0x7:
+		v.t1l = v.t1l&0x00FF | uint16(value)<<8
+		v.clear(ifrT1)
+	case 0x8:
+		v.t2l = value
+	case 0x9:
+		v.t2 = uint16(value)<<8 | uint16(v.t2l)
+		v.t2armed = true
+		v.clear(ifrT2)
+	case 0xA:
+		v.sr = value
+		v.clear(ifrSR)
+		v.startShift()
+	case 0xB:
+		logger.Debugf("ACR $%02x", value)
+		if value&0x80 != 0 && v.acr&0x80 == 0 {
+			v.pb7 = true
+		}
+		v.acr = value
+		v.B.output()
+	case 0xC:
+		logger.Debugf("PCR $%02x", value)
+		v.pcr = value
+		v.A.control()
+		v.B.control()
+	case 0xD:
+		v.clear(value & 0x7F)
+	case 0xE:
+		if value&0x80 != 0 {
+			v.ier |= value & 0x7F
+		} else {
+			v.ier &^= value
+		}
+		v.update()
+	case 0xF:
+		v.A.OR = value
+		v.A.output()
+	}
+	return nil
+}
+
+func (v *VIA) SetWord(addr uint16, value uint16) error {
+	v.Set(addr, byte(value))
+	return v.Set(addr+1, byte(value>>8))
+}
+
+func (v *VIA) Load(bytes []byte) (uint16, error) {
+	return 0x00, fmt.Errorf("VIA: can't load %v bytes into registers", len(bytes))
+}
+
+// Peek reads a register without clearing flags or handshaking
+func (v *VIA) Peek(addr uint16) (byte, error) {
+	switch (addr - v.offset) & 0x0F {
+	case 0x0:
+		return v.B.read(), nil
+	case 0x1, 0xF:
+		return v.A.read(), nil
+	case 0x2:
+		return v.B.DDR, nil
+	case 0x3:
+		return v.A.DDR, nil
+	case 0x4:
+		return byte(v.t1), nil
+	case 0x5:
+		return byte(v.t1 >> 8), nil
+	case 0x6:
+		return byte(v.t1l), nil
+	case 0x7:
+		return byte(v.t1l >> 8), nil
+	case 0x8:
+		return byte(v.t2), nil
+	case 0x9:
+		return byte(v.t2 >> 8), nil
+	case 0xA:
+		return v.sr, nil
+	case 0xB:
+		return v.acr, nil
+	case 0xC:
+		return v.pcr, nil
+	case 0xD:
+		return v.ifr, nil
+	}
+	return v.ier | 0x80, nil
+}
+
+// Reset clears the registers, the timers and the shift register are left
+// alone like on the real chip
+func (v *VIA) Reset() {
+	v.acr = 0
+	v.pcr = 0
+	v.ifr = 0
+	v.ier = 0
+	v.srBits = 0
+	v.t1armed = false
+	v.t2armed = false
+	for _, p := range []*Port{v.A, v.B} {
+		p.DDR = 0
+		p.OR = 0
+		p.control()
+		p.output()
+	}
+	v.update()
+}
+
+// Asserted is the state of the IRQ output
+func (v *VIA) Asserted() bool {
+	return v.irq
+}
+
+// Tick runs the timers and the shift register for cycles
+func (v *VIA) Tick(cycles int) {
+	for ; cycles > 0; cycles-- {
+		v.cycle()
+	}
+}
+
+func (v *VIA) cycle() {
+	if v.t1load {
+		v.t1 = v.t1l
+		v.t1load = false
+	} else {
+		v.t1--
+		if v.t1 == 0xFFFF {
+			v.timeout1()
+		}
+	}
+
+	if v.acr&0x20 == 0 {
+		v.t2--
+		if v.t2 == 0xFFFF && v.t2armed {
+			v.t2armed = false
+			v.set(ifrT2)
+		}
+	}
+
+	if v.srBits > 0 || v.acr&0x1C == 0x10 {
+		switch v.acr & 0x1C {
+		case 0x04, 0x08, 0x10, 0x14, 0x18:
+			v.srTick--
+			if v.srTick <= 0 {
+				v.srTick = v.shiftPeriod()
+				v.shift()
+			}
+		}
+	}
+}
+
+func (v *VIA) timeout1() {
+	free := v.acr&0x40 != 0
+	if v.t1armed {
+		v.set(ifrT1)
+		v.t1armed = free
+		if v.acr&0x80 != 0 {
+			v.pb7 = !v.pb7
+			if !free {
+				v.pb7 = true
+			}
+			v.B.output()
+		}
+	}
+	if free {
+		v.t1load = true
+	}
+}
+
+// SetPB6 drives PB6, T2 counts its falling edges when ACR b5 is set
+func (v *VIA) SetPB6(level bool) {
+	fall := v.pb6 && !level
+	v.pb6 = level
+	if !fall || v.acr&0x20 == 0 {
+		return
+	}
+	v.t2--
+	if v.t2 == 0 && v.t2armed {
+		v.t2armed = false
+		v.set(ifrT2)
+	}
+}
+
+func (v *VIA) shiftPeriod() int {
+	switch v.acr & 0x1C {
+	case 0x08, 0x18: // Φ2
+		return 2
+	}
+	return 2 * (int(v.t2l) + 2)
+}
+
+func (v *VIA) startShift() {
+	switch v.acr & 0x1C {
+	case 0x00:
+		v.srBits = 0
+	default:
+		v.srBits = 8
+		v.srTick = v.shiftPeriod()
+	}
+}
+
+// shift moves one bit, in from CB2 in modes 0xx and out to CB2 in 1xx
+func (v *VIA) shift() {
+	if v.acr&0x10 != 0 {
+		bit := v.sr&0x80 != 0
+		v.sr = v.sr<<1 | v.sr>>7
+		v.B.drive(bit)
+	} else {
+		v.sr <<= 1
+		if v.B.c2 {
+			v.sr |= 1
+		}
+	}
+	if v.acr&0x1C == 0x10 {
+		// free running, no flags
+		return
+	}
+	v.srBits--
+	if v.srBits == 0 {
+		v.set(ifrSR)
+	}
+}
+
+func (v *VIA) set(flags byte) {
+	v.ifr |= flags
+	v.update()
+}
+
+func (v *VIA) clear(flags byte) {
+	v.ifr &^= flags
+	v.update()
+}
+
+func (v *VIA) update() {
+	irq := v.ifr&v.ier&0x7F != 0
+	if irq {
+		v.ifr |= ifrIRQ
+	} else {
+		v.ifr &^= ifrIRQ
+	}
+	if irq != v.irq {
+		v.irq = irq
+		if v.IRQ != nil {
+			v.IRQ(irq)
+		}
+	}
+}
+
+// the port's PCR bits, shifted down to CA's place
+func (p *Port) pcr() byte {
+	if p.b {
+		return p.via.pcr >> 4
+	}
+	return p.via.pcr & 0x0F
+}
+
+func (p *Port) flags() (c1 byte, c2 byte) {
+	if p.b {
+		return ifrCB1, ifrCB2
+	}
+	return ifrCA1, ifrCA2
+}
+
+func (p *Port) read() byte {
+	in := byte(0xFF)
+	if p.Input != nil {
+		in = p.Input()
+	}
+	latch := byte(0x01)
+	if p.b {
+		latch = 0x02
+	}
+	if p.via.acr&latch != 0 {
+		in = p.latch
+	}
+	v := in&^p.DDR | p.OR&p.DDR
+	if p.b && p.via.acr&0x80 != 0 {
+		v &^= 0x80
+		if p.via.pb7 {
+			v |= 0x80
+		}
+	}
+	return v
+}
+
+func (p *Port) output() {
+	if p.Output == nil {
+		return
+	}
+	v := p.OR & p.DDR
+	if p.b && p.via.acr&0x80 != 0 {
+		v &^= 0x80
+		if p.via.pb7 {
+			v |= 0x80
+		}
+	}
+	p.Output(v)
+}
+
+// access clears the flags and handshakes after the CPU uses the port
+// register, handshake is false for port B reads
+func (p *Port) access(handshake bool) {
+	c1, c2 := p.flags()
+	pcr := p.pcr()
+	if pcr&0x0A != 0x02 {
+		// C2 isn't an independent interrupt input
+		c1 |= c2
+	}
+	p.via.clear(c1)
+	if !handshake {
+		return
+	}
+	switch pcr & 0x0E {
+	case 0x08:
+		p.drive(false)
+	case 0x0A:
+		p.drive(false)
+		p.drive(true)
+	}
+}
+
+// control sets C2 after a write to PCR
+func (p *Port) control() {
+	switch p.pcr() & 0x0E {
+	case 0x0C:
+		p.drive(false)
+	case 0x08, 0x0A, 0x0E:
+		p.drive(true)
+	}
+}
+
+// SetC1 drives the C1 line, an active transition sets its flag, latches
+// the port if that's enabled, and ends a C2 handshake
+func (p *Port) SetC1(level bool) {
+	if level == p.c1 {
+		return
+	}
+	p.c1 = level
+	v := p.via
+	if p.b && v.srBits > 0 && v.acr&0x0C == 0x0C {
+		// the shift register on an external clock, in on rising edges and
+		// out on falling ones
+		if level == (v.acr&0x10 == 0) {
+			v.shift()
+		}
+	}
+	if level != (p.pcr()&0x01 != 0) {
+		return
+	}
+	p.latch = 0xFF
+	if p.Input != nil {
+		p.latch = p.Input()
+	}
+	c1, _ := p.flags()
+	v.set(c1)
+	if p.pcr()&0x0E == 0x08 {
+		p.drive(true)
+	}
+}
+
+// SetC2 drives the C2 line, it's ignored while C2 is an output
+func (p *Port) SetC2(level bool) {
+	if level == p.c2 {
+		return
+	}
+	p.c2 = level
+	pcr := p.pcr()
+	if pcr&0x08 != 0 || level != (pcr&0x04 != 0) {
+		return
+	}
+	_, c2 := p.flags()
+	p.via.set(c2)
+}
+
+func (p *Port) drive(level bool) {
+	if level == p.out {
+		return
+	}
+	p.out = level
+	if p.C2 != nil {
+		p.C2(level)
+	}
+}
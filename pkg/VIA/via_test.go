@@ -0,0 +1,150 @@
+package VIA
+
+import "testing"
+
+// irqs runs v a cycle at a time and returns the cycles its IRQ asserted
+// on, each one acknowledged by reading T1C-L like a handler would
+func irqs(v *VIA, cycles int) []int {
+	var at []int
+	now := 0
+	v.IRQ = func(asserted bool) {
+		if asserted {
+			at = append(at, now)
+		}
+	}
+	for now = 1; now <= cycles; now++ {
+		v.Tick(1)
+		if v.Asserted() {
+			v.Get(0x6004)
+		}
+	}
+	return at
+}
+
+func TestT1FreeRun(t *testing.T) {
+	v := New(0x6000)
+	v.Set(0x600B, 0x40) // free run
+	v.Set(0x600E, 0xC0)
+	v.Set(0x6004, 0x00)
+	v.Set(0x6005, 0x01) // $0100
+
+	// N+1 to the first, every N+2 after that
+	want := []int{0x101, 0x203, 0x305, 0x407}
+	at := irqs(v, 0x407)
+	if len(at) != len(want) {
+		t.Fatalf("IRQ at %v, want %v", at, want)
+	}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Fatalf("IRQ at %v, want %v", at, want)
+		}
+	}
+}
+
+func TestT1OneShot(t *testing.T) {
+	v := New(0x6000)
+	v.Set(0x600E, 0xC0)
+	v.Set(0x6004, 0x10)
+	v.Set(0x6005, 0x00)
+	if at := irqs(v, 0x100); len(at) != 1 || at[0] != 0x11 {
+		t.Fatalf("IRQ at %v, want once at 17", at)
+	}
+}
+
+func TestPB7(t *testing.T) {
+	v := New(0x6000)
+	var pb7 []bool
+	v.B.Output = func(value byte) {
+		pb7 = append(pb7, value&0x80 != 0)
+	}
+	v.Set(0x600B, 0xC0) // free run on PB7
+	v.Set(0x6004, 0x08)
+	v.Set(0x6005, 0x00)
+	pb7 = nil
+	v.Tick(9 + 10 + 10)
+	if len(pb7) != 3 || !pb7[0] || pb7[1] || !pb7[2] {
+		t.Fatalf("PB7 went %v, want it to toggle 3 times from low", pb7)
+	}
+}
+
+func TestT2(t *testing.T) {
+	v := New(0x6000)
+	v.Set(0x600E, 0xA0)
+	v.Set(0x6008, 0x20)
+	v.Set(0x6009, 0x00)
+	if at := irqs(v, 0x100); len(at) != 1 || at[0] != 0x21 {
+		t.Fatalf("IRQ at %v, want once at 33", at)
+	}
+	if ifr, _ := v.Peek(0x600D); ifr&0x20 == 0 {
+		t.Error("T1C-L acknowledged T2's flag")
+	}
+	v.Get(0x6008)
+	if v.Asserted() {
+		t.Error("IRQ still asserted after reading T2C-L")
+	}
+}
+
+func TestShiftOut(t *testing.T) {
+	v := New(0x6000)
+	cb2 := true
+	v.B.C2 = func(level bool) {
+		cb2 = level
+	}
+	v.Set(0x600B, 0x18) // out under Φ2
+	v.Set(0x600A, 0xA5)
+	var bits byte
+	for i := 0; i < 8; i++ {
+		v.Tick(2)
+		bits <<= 1
+		if cb2 {
+			bits |= 1
+		}
+	}
+	if bits != 0xA5 {
+		t.Errorf("shifted $%02x out on CB2, want $A5", bits)
+	}
+	if ifr, _ := v.Peek(0x600D); ifr&0x04 == 0 {
+		t.Error("no SR flag after 8 shifts")
+	}
+}
+
+func TestCA1(t *testing.T) {
+	v := New(0x6000)
+	in := byte(0x3C)
+	v.A.Input = func() byte {
+		return in
+	}
+	v.Set(0x600B, 0x01) // latch port A
+	v.Set(0x600C, 0x01) // CA1 rising
+	v.Set(0x600E, 0x82)
+	v.A.SetC1(true)
+	in = 0x00
+	if !v.Asserted() {
+		t.Fatal("CA1 didn't interrupt")
+	}
+	if a, _ := v.Get(0x6001); a != 0x3C {
+		t.Errorf("port A read $%02x, want $3C latched by CA1", a)
+	}
+	if v.Asserted() {
+		t.Error("IRQ still asserted after reading port A")
+	}
+}
+
+func TestReset(t *testing.T) {
+	v := New(0x6000)
+	asserted := false
+	v.IRQ = func(a bool) {
+		asserted = a
+	}
+	v.Set(0x600E, 0xC0)
+	v.Set(0x6004, 0x01)
+	v.Set(0x6005, 0x00)
+	v.Tick(2)
+	if !asserted {
+		t.Fatal("T1 didn't interrupt")
+	}
+	v.Reset()
+	if asserted {
+		t.Error("IRQ still asserted after a reset")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zoul0813/go6502/pkg/Serial"
+)
+
+// -serial [NAME=]SPEC, without a name it's for the first UART
+var serialPorts = make(map[string]string)
+
+func addSerialPort(s string) error {
+	name, spec := "", s
+	if i := strings.Index(s, "="); i >= 0 {
+		name, spec = s[:i], s[i+1:]
+	}
+	if _, ok := serialPorts[name]; ok {
+		return fmt.Errorf("more than one -serial for %q", name)
+	}
+	serialPorts[name] = spec
+	return nil
+}
+
+// startSerial opens the host end of every UART that has one, -serial
+// overrides the machine's.  It reports whether stdio was taken.
+func startSerial() (bool, error) {
+	stdio := false
+	used := 0
+	for i, u := range board.UARTs {
+		spec := u.Serial
+		if s, ok := serialPorts[u.Name]; ok {
+			spec = s
+			used++
+		} else if s, ok := serialPorts[""]; ok && i == 0 {
+			spec = s
+			used++
+		}
+		if len(spec) == 0 {
+			continue
+		}
+		if spec == "stdio" {
+			if stdio {
+				return false, fmt.Errorf("%v: only one serial port can use stdio", u.Name)
+			}
+			stdio = true
+		}
+		host, err := Serial.Open(spec)
+		if err != nil {
+			return false, fmt.Errorf("%v: %v", u.Name, err)
+		}
+		atExit(func() { host.Close() })
+		u.Chip.Connect(host)
+		if spec != "stdio" {
+			fmt.Fprintf(os.Stderr, "%v serial port on %v\n", u.Name, host.Name)
+		}
+	}
+	if used < len(serialPorts) {
+		return false, fmt.Errorf("-serial: %v has no such UART", config.Name)
+	}
+	return stdio, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tapeCommand runs a deck control for the debugger and the GUI and returns
+// what to tell the user, the machine lock must not be held
+func tapeCommand(cmd string, arg string) (string, error) {
+	if deck == nil {
+		return "", fmt.Errorf("%v has no cassette interface", config.Name)
+	}
+	machine.Lock()
+	defer machine.Unlock()
+
+	var err error
+	switch cmd {
+	case "", "status":
+	case "insert":
+		if len(arg) == 0 {
+			return "", fmt.Errorf("insert needs a WAV file or tape image")
+		}
+		err = deck.Open(arg)
+	case "eject":
+		err = deck.Eject()
+	case "rewind":
+		err = deck.Rewind()
+	case "play":
+		err = deck.Play()
+	case "stop":
+		err = deck.Stop()
+	case "toggle":
+		if deck.Running() {
+			err = deck.Stop()
+		} else {
+			err = deck.Play()
+		}
+	case "record":
+		if len(arg) == 0 {
+			return "", fmt.Errorf("record needs a file, .wav for audio or anything else for an image")
+		}
+		err = deck.Record(arg)
+	case "fast":
+		switch arg {
+		case "", "on":
+			deck.Fast = true
+		case "off":
+			deck.Fast = false
+		default:
+			return "", fmt.Errorf("fast is on or off")
+		}
+	case "list":
+		var b strings.Builder
+		for i, block := range deck.Blocks() {
+			fmt.Fprintf(&b, "%v: $%04x-$%04x, %v bytes\n", i+1, block.Start, block.End(), len(block.Data))
+		}
+		return b.String() + deck.Status(), nil
+	default:
+		return "", fmt.Errorf("unknown tape command %q", cmd)
+	}
+	if err != nil {
+		return "", err
+	}
+	return deck.Status(), nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zoul0813/go6502/pkg/Telnet"
+)
+
+func startTelnet(addr string, inputMode string, raw bool) error {
+	mode, err := Telnet.ParseMode(inputMode)
+	if err != nil {
+		return err
+	}
+
+	server := Telnet.New(display, mode)
+	server.Telnet = !raw
+	server.OnKey = typeKey
+	server.Screen = func() string {
+		return display.All(false)
+	}
+	if err := server.Listen(addr); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %v (%v input)\n", server.Addr(), mode)
+	return nil
+}
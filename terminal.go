@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zoul0813/go6502/pkg/Terminal"
+)
+
+func runTerminal() int {
+	t := Terminal.New(display)
+	t.OnKey = typeKey
+	t.OnReset = requestReset
+	t.OnDebug = func() {
+		// the console takes the machine lock itself for the tape deck, so
+		// only hold it to pause
+		was := setPaused(true)
+		DebugConsole(cpu, io)
+		setPaused(was)
+	}
+
+	go processTicks()
+	if err := t.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Terminal Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/zoul0813/go6502/pkg/IO"
 	"github.com/zoul0813/go6502/pkg/Keyboard"
 	"github.com/zoul0813/go6502/pkg/Memory"
+	"github.com/zoul0813/go6502/pkg/PIA"
 )
 
 func main() {
@@ -15,19 +16,17 @@ func main() {
 	// RAM
 	ram := Memory.New(0x8000, 0x0000, false)
 
-	// Display
-	display := Display.New(0xD012, 40, 25)
-
-	// Keyboard
-	keyboard := Keyboard.New(0xD010)
+	// PIA, keyboard on port A and display on port B
+	pia := PIA.New(0xD010)
+	Keyboard.New().Connect(pia.A)
+	Display.New(40, 25, IO.NewClock(1_000_000)).Connect(pia.B)
 
 	// ROM
 	rom := Memory.New(0x1000, 0xF000, true)
 
 	devices := []*IO.Device{
 		IO.NewDevice("RAM", ram, 0x0000),
-		IO.NewDevice("Keyboard", keyboard, 0xD010),
-		IO.NewDevice("Display", display, 0xD012),
+		IO.NewDevice("PIA", pia, 0xD010),
 		IO.NewDevice("ROM", rom, 0xF000),
 	}
 	io := IO.New(devices)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zoul0813/go6502/pkg/IO"
+)
+
+// watchpoints are IO hooks that stop the CPU, like a breakpoint on data
+type watchpoint struct {
+	Id     int    `json:"id"`
+	Access string `json:"access"`
+	Start  uint16 `json:"start"`
+	End    uint16 `json:"end"`
+	Hits   int    `json:"hits"`
+}
+
+var watchpoints = make(map[int]*watchpoint)
+
+// addWatch stops the CPU on every access to start..end, the machine lock
+// must not be held
+func addWatch(access IO.Access, start uint16, end uint16) *watchpoint {
+	machine.Lock()
+	defer machine.Unlock()
+	w := &watchpoint{
+		Access: access.String(),
+		Start:  start,
+		End:    end,
+	}
+	// the hook runs inside step, with the machine lock held
+	w.Id = io.AddHook(access, start, end, func(a IO.Access, addr uint16, value byte, pc uint16) {
+		w.Hits++
+		if !cpu.SingleStep {
+			cpu.SingleStep = true
+			fmt.Printf("Watchpoint %v: %v $%02x at $%04x, PC %v\n", w.Id, a, value, addr, where(pc))
+		}
+	})
+	watchpoints[w.Id] = w
+	return w
+}
+
+func removeWatch(id int) bool {
+	machine.Lock()
+	defer machine.Unlock()
+	if _, ok := watchpoints[id]; !ok {
+		return false
+	}
+	io.RemoveHook(id)
+	delete(watchpoints, id)
+	return true
+}
+
+func listWatches() []watchpoint {
+	machine.Lock()
+	defer machine.Unlock()
+	list := make([]watchpoint, 0, len(watchpoints))
+	for _, w := range watchpoints {
+		list = append(list, *w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+/*
+	Browser front end
+
+	The page in web/ draws the screen on a canvas and talks to /ws with JSON
+	messages.
+
+	server -> browser
+	{"type": "screen", "text": "...", "col": 0, "row": 0, "cols": 40, "rows": 25}
+	{"type": "debug", "registers": "...", "zeropage": "...", "stack": "...", "wozin": "..."}
+
+	browser -> server
+	{"type": "key", "key": 13}
+	{"type": "reset"}
+*/
+
+//go:embed web assets/fonts/C64_Pro_Mono-STYLE.ttf
+var webFiles embed.FS
+
+const (
+	webFrameRate = 30
+	webDebugRate = 4
+)
+
+var webScreenVersion atomic.Uint64
+
+type webScreen struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Col  int    `json:"col"`
+	Row  int    `json:"row"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+type webDebug struct {
+	Type      string `json:"type"`
+	Registers string `json:"registers"`
+	ZeroPage  string `json:"zeropage"`
+	Stack     string `json:"stack"`
+	WozIn     string `json:"wozin"`
+}
+
+type webMessage struct {
+	Type string `json:"type"`
+	Key  byte   `json:"key"`
+}
+
+func startWeb(addr string) error {
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	display.Listen(func(c byte) {
+		webScreenVersion.Add(1)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/", "/index.html":
+			webServe(w, r, "web/index.html")
+		case "/app.js":
+			webServe(w, r, "web/app.js")
+		case "/font.ttf":
+			webServe(w, r, "assets/fonts/C64_Pro_Mono-STYLE.ttf")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.Handle("/ws", websocket.Server{
+		Handler:   webSocket,
+		Handshake: webHandshake,
+	})
+
+	fmt.Fprintf(os.Stderr, "Web front end on http://%v/\n", l.Addr())
+	go http.Serve(l, mux)
+	return nil
+}
+
+// webHandshake refuses sockets opened by pages from anywhere but localhost,
+// see localRequest
+func webHandshake(config *websocket.Config, r *http.Request) error {
+	if len(r.Header.Get("Origin")) == 0 {
+		return fmt.Errorf("missing origin")
+	}
+	return localRequest(r)
+}
+
+func webServe(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := webFiles.ReadFile(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(data))
+}
+
+func webSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	go webRead(ws)
+
+	frame := time.NewTicker(time.Second / webFrameRate)
+	defer frame.Stop()
+	var version uint64 = ^uint64(0)
+	debugEvery := webFrameRate / webDebugRate
+	for n := 0; ; n++ {
+		<-frame.C
+		if v := webScreenVersion.Load(); v != version {
+			version = v
+			col, row := display.Cursor()
+			cols, rows := display.Dimensions()
+			s := webScreen{
+				Type: "screen",
+				Text: display.All(false),
+				Col:  col,
+				Row:  row,
+				Cols: cols,
+				Rows: rows,
+			}
+			if err := websocket.JSON.Send(ws, s); err != nil {
+				return
+			}
+		}
+		if n%debugEvery == 0 {
+			// the snapshot is taken between instructions
+			machine.Lock()
+			d := webDebug{
+				Type:      "debug",
+				Registers: cpu.RegisterString(),
+				ZeroPage:  io.DumpString(0x00, 0xFF),
+				Stack:     io.DumpString(0x0100, 0xFF),
+				WozIn:     io.DumpString(0x0200, 0xFF),
+			}
+			machine.Unlock()
+			if err := websocket.JSON.Send(ws, d); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func webRead(ws *websocket.Conn) {
+	for {
+		var m webMessage
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			ws.Close()
+			return
+		}
+		switch m.Type {
+		case "key":
+			typeKey(m.Key)
+		case "reset":
+			requestReset()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Apple-1 screen in a canvas, fed over a WebSocket, see web.go
+(function () {
+	const scale = 2;
+	const fontSize = 8 * scale;
+	const color = "#2fbf3f";
+
+	const canvas = document.getElementById("screen");
+	const ctx = canvas.getContext("2d");
+	const status = document.getElementById("status");
+	let screen = { text: "", col: 0, row: 0, cols: 40, rows: 25 };
+	let blink = false;
+	let ws;
+
+	function resize() {
+		canvas.width = screen.cols * fontSize;
+		canvas.height = screen.rows * fontSize;
+	}
+
+	function draw() {
+		ctx.fillStyle = "#000";
+		ctx.fillRect(0, 0, canvas.width, canvas.height);
+		ctx.fillStyle = color;
+		ctx.font = fontSize + "px C64";
+		ctx.textBaseline = "top";
+		const lines = screen.text.split("\n");
+		for (let r = 0; r < screen.rows && r < lines.length; r++) {
+			for (let c = 0; c < lines[r].length; c++) {
+				let ch = lines[r][c];
+				if (r === screen.row && c === screen.col && blink) {
+					ch = "@";
+				}
+				if (ch !== " " && ch !== "\0") {
+					ctx.fillText(ch, c * fontSize, r * fontSize);
+				}
+			}
+		}
+	}
+
+	function send(msg) {
+		if (ws && ws.readyState === WebSocket.OPEN) {
+			ws.send(JSON.stringify(msg));
+		}
+	}
+
+	function connect() {
+		ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+		ws.onopen = function () {
+			status.firstChild.textContent = "connected ";
+		};
+		ws.onclose = function () {
+			status.firstChild.textContent = "disconnected, retrying... ";
+			setTimeout(connect, 1000);
+		};
+		ws.onmessage = function (e) {
+			const msg = JSON.parse(e.data);
+			switch (msg.type) {
+				case "screen":
+					if (msg.cols !== screen.cols || msg.rows !== screen.rows) {
+						screen = msg;
+						resize();
+					}
+					screen = msg;
+					draw();
+					break;
+				case "debug":
+					document.getElementById("registers").textContent = msg.registers;
+					document.getElementById("zeropage").textContent = msg.zeropage;
+					document.getElementById("wozin").textContent = msg.wozin;
+					document.getElementById("stack").textContent = msg.stack;
+					break;
+			}
+		};
+	}
+
+	canvas.addEventListener("keydown", function (e) {
+		let key = 0;
+		switch (e.key) {
+			case "Enter": key = 0x0D; break;
+			case "Escape": key = 0x1B; break;
+			case "Backspace": key = 0x08; break;
+			default:
+				if (e.key.length === 1 && !e.ctrlKey && !e.metaKey) {
+					key = e.key.charCodeAt(0);
+				}
+		}
+		if (key > 0 && key < 0x80) {
+			send({ type: "key", key: key });
+			e.preventDefault();
+		}
+	});
+
+	document.getElementById("reset").addEventListener("click", function () {
+		send({ type: "reset" });
+		canvas.focus();
+	});
+
+	setInterval(function () {
+		blink = !blink;
+		draw();
+	}, 500);
+
+	resize();
+	document.fonts.load(fontSize + "px C64").then(draw);
+	connect();
+	canvas.focus();
+})();